@@ -0,0 +1,46 @@
+// Command slorules writes the Prometheus recording and burn-rate alert rules of the SLOs
+// declared in a YAML config, in the format read by slo.Load:
+//
+//	go run github.com/kyon1313/observability/cmd/slorules -config slo.yml -output prometheus/slo.rules.yml
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kyon1313/observability/slo"
+)
+
+const header = "# Code generated by slorules. DO NOT EDIT.\n"
+
+func main() {
+	var (
+		config = flag.String("config", "", "YAML file declaring the SLOs (required)")
+		output = flag.String("output", "slo.rules.yml", "rule file to write")
+	)
+	flag.Parse()
+
+	if *config == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*config)
+	if err != nil {
+		log.Fatalf("slorules: %v", err)
+	}
+	defer f.Close()
+
+	slos, err := slo.Load(f)
+	if err != nil {
+		log.Fatalf("slorules: %v", err)
+	}
+	rules, err := slo.Rules(slos...)
+	if err != nil {
+		log.Fatalf("slorules: %v", err)
+	}
+	if err := os.WriteFile(*output, append([]byte(header), rules...), 0o644); err != nil {
+		log.Fatalf("slorules: %v", err)
+	}
+}
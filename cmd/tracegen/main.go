@@ -0,0 +1,466 @@
+// Command tracegen writes a tracing decorator for a Go interface.
+//
+// Add a directive next to the interface and run go generate:
+//
+//	//go:generate go run github.com/kyon1313/observability/cmd/tracegen -type UserRepository
+//
+// Every method whose first parameter is a context.Context gets a span named
+// <pkg>.<Type>.<Method>, its arguments as span attributes, its error recorded and
+// its duration observed in the method_duration_seconds histogram. Other methods are
+// delegated unchanged. Parameters listed in -sensitive, or in a
+// "//tracegen:sensitive name,..." comment on the method, are not recorded.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	tracingPath = "github.com/kyon1313/observability/tracing"
+	metricsPath = "github.com/kyon1313/observability/metrics"
+)
+
+func main() {
+	var (
+		typeName  = flag.String("type", "", "name of the interface to decorate (required)")
+		dir       = flag.String("dir", ".", "directory of the package declaring the interface")
+		output    = flag.String("output", "", "output file name; default <type>_traced.go in -dir")
+		sensitive = flag.String("sensitive", "password,secret,token", "comma-separated parameter names never recorded as attributes")
+	)
+	flag.Parse()
+
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, err := generate(*dir, *typeName, splitList(*sensitive))
+	if err != nil {
+		log.Fatalf("tracegen: %v", err)
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.ToLower(*typeName) + "_traced.go"
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(*dir, out)
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		log.Fatalf("tracegen: %v", err)
+	}
+}
+
+type param struct {
+	Name      string
+	Attr      string
+	Type      string
+	Variadic  bool
+	Sensitive bool
+}
+
+type method struct {
+	Name     string
+	SpanName string
+	Params   []param
+	Results  []string
+	HasError bool
+	Traced   bool
+}
+
+type decorator struct {
+	Package   string
+	Type      string
+	Imports   []string
+	Tracing   string
+	Metrics   string
+	Time      string
+	Methods   []method
+	AnyTraced bool
+}
+
+func generate(dir, typeName string, sensitive []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, iface, err := findInterface(fset, dir, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decorator{Package: file.Name.Name, Type: typeName}
+	imports := fileImports(file)
+	used := make(map[string]bool)
+
+	var fns []*ast.FuncType
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", typeName)
+		}
+		fns = append(fns, fn)
+		d.AnyTraced = d.AnyTraced || isTraced(fn, imports)
+	}
+
+	// Resolve the packages used by the method bodies first, so that parameters named
+	// after them can be renamed instead of shadowing them.
+	d.Tracing = imports.require(tracingPath, "apw_tracing")
+	reserved := map[string]bool{"d": true, "span": true, "start": true, "err": true, d.Tracing: true}
+	if d.AnyTraced {
+		d.Metrics = imports.require(metricsPath, "metrics")
+		d.Time = imports.require("time", "time")
+		reserved[d.Metrics], reserved[d.Time] = true, true
+	}
+
+	for i, field := range iface.Methods.List {
+		fn := fns[i]
+		collectPackages(fn, used)
+
+		m := method{
+			Name:     field.Names[0].Name,
+			SpanName: fmt.Sprintf("%s.%s.%s", d.Package, typeName, field.Names[0].Name),
+		}
+		skip := append(append([]string{}, sensitive...), directiveList(field.Doc, "tracegen:sensitive")...)
+		m.Params = params(fset, fn, skip, reserved)
+		m.Results, m.HasError = results(fset, fn)
+		m.Traced = isTraced(fn, imports)
+		d.Methods = append(d.Methods, m)
+	}
+
+	for name := range used {
+		imports.use(name)
+	}
+	d.Imports = imports.specs()
+
+	var buf bytes.Buffer
+	if err := decoratorTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+func findInterface(fset *token.FileSet, dir, typeName string) (*ast.File, *ast.InterfaceType, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_traced.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != typeName {
+					continue
+				}
+				iface, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return nil, nil, fmt.Errorf("%s is not an interface", typeName)
+				}
+				return file, iface, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("interface %s not found in %s", typeName, dir)
+}
+
+// isTraced reports whether the method takes a context.Context first and so gets a span.
+func isTraced(fn *ast.FuncType, imports *importSet) bool {
+	return len(fn.Params.List) > 0 && imports.isContext(fn.Params.List[0].Type)
+}
+
+// params returns the parameters of fn, renaming those that are unnamed or clash with
+// the reserved names used in the generated method bodies.
+func params(fset *token.FileSet, fn *ast.FuncType, sensitive []string, reserved map[string]bool) []param {
+	var out []param
+	for _, field := range fn.Params.List {
+		typ := field.Type
+		variadic := false
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			typ, variadic = ellipsis.Elt, true
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: ""}}
+		}
+		for _, name := range names {
+			p := param{Name: name.Name, Attr: "arg." + name.Name, Type: exprString(fset, typ), Variadic: variadic}
+			p.Sensitive = contains(sensitive, p.Name)
+			if p.Name == "" || p.Name == "_" || reserved[p.Name] || strings.HasPrefix(p.Name, "r") && isResultName(p.Name) {
+				p.Name = "a" + strconv.Itoa(len(out))
+				if p.Attr == "arg." || p.Attr == "arg._" {
+					p.Attr = "arg." + strconv.Itoa(len(out))
+				}
+			}
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func results(fset *token.FileSet, fn *ast.FuncType) ([]string, bool) {
+	if fn.Results == nil {
+		return nil, false
+	}
+
+	var out []string
+	for _, field := range fn.Results.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, exprString(fset, field.Type))
+		}
+	}
+	return out, len(out) > 0 && out[len(out)-1] == "error"
+}
+
+func isResultName(name string) bool {
+	_, err := strconv.Atoi(strings.TrimPrefix(name, "r"))
+	return err == nil
+}
+
+func collectPackages(node ast.Node, used map[string]bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+// directiveList returns the comma-separated values of a "//<name> a,b" comment.
+func directiveList(doc *ast.CommentGroup, name string) []string {
+	if doc == nil {
+		return nil
+	}
+	var out []string
+	for _, c := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if rest, ok := strings.CutPrefix(text, name); ok {
+			out = append(out, splitList(rest)...)
+		}
+	}
+	return out
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// importSet tracks the imports of the source file and which of them the generated file needs.
+type importSet struct {
+	byName map[string]string // local name -> path
+	used   map[string]bool   // local names to emit
+}
+
+func fileImports(file *ast.File) *importSet {
+	s := &importSet{byName: make(map[string]string), used: make(map[string]bool)}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		s.byName[name] = path
+	}
+	return s
+}
+
+// isContext reports whether expr refers to context.Context under any import name.
+func (s *importSet) isContext(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && s.byName[ident.Name] == "context"
+}
+
+// require makes sure path is imported and returns its local name.
+func (s *importSet) require(path, name string) string {
+	for n, p := range s.byName {
+		if p == path {
+			s.used[n] = true
+			return n
+		}
+	}
+	// Alias the import if the source file already uses its name for another package.
+	for s.byName[name] != "" {
+		name = "tracegen_" + name
+	}
+	s.byName[name] = path
+	s.used[name] = true
+	return name
+}
+
+func (s *importSet) use(name string) {
+	if _, ok := s.byName[name]; ok {
+		s.used[name] = true
+	}
+}
+
+// specs returns the import lines, standard library first, then the rest separated by a blank line.
+func (s *importSet) specs() []string {
+	var std, other []string
+	for name := range s.used {
+		path := s.byName[name]
+		spec := strconv.Quote(path)
+		if name != filepath.Base(path) {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	if len(std) > 0 && len(other) > 0 {
+		std = append(std, "")
+	}
+	return append(std, other...)
+}
+
+var decoratorTemplate = template.Must(template.New("decorator").Funcs(template.FuncMap{
+	"signature": func(m method) string {
+		parts := make([]string, len(m.Params))
+		for i, p := range m.Params {
+			if p.Variadic {
+				parts[i] = p.Name + " ..." + p.Type
+			} else {
+				parts[i] = p.Name + " " + p.Type
+			}
+		}
+		return strings.Join(parts, ", ")
+	},
+	"arguments": func(m method) string {
+		parts := make([]string, len(m.Params))
+		for i, p := range m.Params {
+			parts[i] = p.Name
+			if p.Variadic {
+				parts[i] += "..."
+			}
+		}
+		return strings.Join(parts, ", ")
+	},
+	"results": func(m method) string {
+		if len(m.Results) == 0 {
+			return ""
+		}
+		if !m.Traced {
+			if len(m.Results) == 1 {
+				return m.Results[0]
+			}
+			return "(" + strings.Join(m.Results, ", ") + ")"
+		}
+		parts := make([]string, len(m.Results))
+		for i, r := range m.Results {
+			name := "r" + strconv.Itoa(i)
+			if m.HasError && i == len(m.Results)-1 {
+				name = "err"
+			}
+			parts[i] = name + " " + r
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	},
+}).Parse(`// Code generated by tracegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+type traced{{.Type}} struct {
+	next    {{.Type}}
+	tracer  {{.Tracing}}.OtelTracing
+{{- if .AnyTraced}}
+	metrics *{{.Metrics}}.Metrics
+{{- end}}
+}
+
+// NewTraced{{.Type}} wraps next so that every context-aware call is traced{{if .AnyTraced}} and timed{{end}}.
+func NewTraced{{.Type}}(next {{.Type}}, tracer {{.Tracing}}.OtelTracing{{if .AnyTraced}}, m *{{.Metrics}}.Metrics{{end}}) {{.Type}} {
+	return &traced{{.Type}}{next: next, tracer: tracer{{if .AnyTraced}}, metrics: m{{end}}}
+}
+{{range $m := .Methods}}
+func (d *traced{{$.Type}}) {{$m.Name}}({{signature $m}}) {{results $m}} {
+{{- if $m.Traced}}
+	{{(index $m.Params 0).Name}}, span := d.tracer.StartSpan({{(index $m.Params 0).Name}}, "{{$m.SpanName}}")
+	start := {{$.Time}}.Now()
+{{- range $i, $p := $m.Params}}{{if and $i (not $p.Sensitive)}}
+	d.tracer.AddAttribute(span, "{{$p.Attr}}", {{$p.Name}})
+{{- end}}{{end}}
+{{- if $m.HasError}}
+	defer func() {
+		{{$.Metrics}}.ObserveMethodDuration(d.metrics, "{{$m.SpanName}}", start, err)
+		if err != nil {
+			d.tracer.AddAttribute(span, "error.source", "{{$m.SpanName}}")
+		}
+		d.tracer.EndSpan(span, err)
+	}()
+{{- else}}
+	defer func() {
+		{{$.Metrics}}.ObserveMethodDuration(d.metrics, "{{$m.SpanName}}", start, nil)
+		d.tracer.EndSpan(span, nil)
+	}()
+{{- end}}
+{{end}}
+	{{if $m.Results}}return {{end}}d.next.{{$m.Name}}({{arguments $m}})
+}
+{{end}}`))
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generateSource writes src to a package directory and generates the decorator of typeName.
+func generateSource(t *testing.T, src, typeName string, sensitive ...string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "store.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := generate(dir, typeName, sensitive)
+	return string(out), err
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "traced method",
+			src: `package store
+
+import "context"
+
+type Store interface {
+	Get(ctx context.Context, id int64) (string, error)
+}
+`,
+			want: []string{
+				`"github.com/kyon1313/observability/metrics"`,
+				`func NewTracedStore(next Store, tracer apw_tracing.OtelTracing, m *metrics.Metrics) Store`,
+				`func (d *tracedStore) Get(ctx context.Context, id int64) (r0 string, err error)`,
+				`ctx, span := d.tracer.StartSpan(ctx, "store.Store.Get")`,
+				`d.tracer.AddAttribute(span, "arg.id", id)`,
+				`metrics.ObserveMethodDuration(d.metrics, "store.Store.Get", start, err)`,
+				`return d.next.Get(ctx, id)`,
+			},
+		},
+		{
+			name: "sensitive parameters",
+			src: `package store
+
+import "context"
+
+type Store interface {
+	//tracegen:sensitive pin
+	Login(ctx context.Context, user, password, pin string) error
+}
+`,
+			want:    []string{`d.tracer.AddAttribute(span, "arg.user", user)`},
+			notWant: []string{`"arg.password"`, `"arg.pin"`},
+		},
+		{
+			name: "method without context is delegated",
+			src: `package store
+
+type Store interface {
+	Len() int
+}
+`,
+			want:    []string{"func (d *tracedStore) Len() int {\n\treturn d.next.Len()\n}"},
+			notWant: []string{"StartSpan", `"time"`, "metrics"},
+		},
+		{
+			name: "unnamed and shadowing parameters are renamed",
+			src: `package store
+
+import (
+	"context"
+	"time"
+)
+
+type Store interface {
+	Expire(context.Context, string, time.Duration, ...string) error
+	Touch(ctx context.Context, metrics, span, r1 string) error
+}
+`,
+			want: []string{
+				`func (d *tracedStore) Expire(a0 context.Context, a1 string, a2 time.Duration, a3 ...string) (err error)`,
+				`d.tracer.AddAttribute(span, "arg.1", a1)`,
+				`return d.next.Expire(a0, a1, a2, a3...)`,
+				`func (d *tracedStore) Touch(ctx context.Context, a1 string, a2 string, a3 string) (err error)`,
+				`d.tracer.AddAttribute(span, "arg.metrics", a1)`,
+			},
+		},
+		{
+			name: "imports named like generated ones are aliased",
+			src: `package store
+
+import (
+	"context"
+
+	metrics "example.com/billing/metrics"
+)
+
+type Store interface {
+	Charge(ctx context.Context, usage metrics.Usage) error
+}
+`,
+			want: []string{
+				`tracegen_metrics "github.com/kyon1313/observability/metrics"`,
+				`tracegen_metrics.ObserveMethodDuration(d.metrics,`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateSource(t, tt.src, "Store", "password")
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("generated code lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("generated code contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing", "package store\n", "interface Store not found"},
+		{"not an interface", "package store\n\ntype Store struct{}\n", "Store is not an interface"},
+		{"embedded interface", "package store\n\nimport \"io\"\n\ntype Store interface {\n\tio.Closer\n}\n", "embedded interfaces are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateSource(t, tt.src, "Store")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("generate error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestExampleDecoratorsAreUpToDate regenerates the decorators committed in the example.
+func TestExampleDecoratorsAreUpToDate(t *testing.T) {
+	for _, tt := range []struct{ dir, typeName, file string }{
+		{"../../example/repo", "UserRepository", "userrepository_traced.go"},
+		{"../../example/service", "UserService", "userservice_traced.go"},
+	} {
+		want, err := os.ReadFile(filepath.Join(tt.dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := generate(tt.dir, tt.typeName, splitList("password,secret,token"))
+		if err != nil {
+			t.Fatalf("generate %s: %v", tt.typeName, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date; run go generate ./example/...", tt.file)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+type tracedConn struct {
+	conn   driver.Conn
+	driver *tracedDriver
+}
+
+var (
+	_ driver.Conn               = (*tracedConn)(nil)
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+	_ driver.SessionResetter    = (*tracedConn)(nil)
+	_ driver.Validator          = (*tracedConn)(nil)
+	_ driver.NamedValueChecker  = (*tracedConn)(nil)
+)
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	ctx, span := c.driver.startSpan(ctx, "sql.Prepare", query)
+	defer func() { c.driver.endSpan(span, err) }()
+
+	if cp, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = cp.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{stmt: stmt, query: query, driver: c.driver}, nil
+}
+
+func (c *tracedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	spanCtx, span := c.driver.startSpan(ctx, "sql.Begin", "")
+	defer func() { c.driver.endSpan(span, err) }()
+
+	if cb, ok := c.conn.(driver.ConnBeginTx); ok {
+		tx, err = cb.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{tx: tx, ctx: ctx, driver: c.driver}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		legacy, ok := c.conn.(driver.Execer)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		_, span := c.driver.startSpan(ctx, "sql.Exec", query)
+		result, err = legacy.Exec(query, values)
+		c.driver.endExec(span, result, err)
+		return result, err
+	}
+
+	ctx, span := c.driver.startSpan(ctx, "sql.Exec", query)
+	result, err = execer.ExecContext(ctx, query, args)
+	c.driver.endExec(span, result, err)
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		legacy, ok := c.conn.(driver.Queryer)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		_, span := c.driver.startSpan(ctx, "sql.Query", query)
+		rows, err = legacy.Query(query, values)
+		return c.driver.endQuery(span, rows, err)
+	}
+
+	ctx, span := c.driver.startSpan(ctx, "sql.Query", query)
+	rows, err = queryer.QueryContext(ctx, query, args)
+	return c.driver.endQuery(span, rows, err)
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	stmt   driver.Stmt
+	query  string
+	driver *tracedDriver
+}
+
+var (
+	_ driver.StmtExecContext   = (*tracedStmt)(nil)
+	_ driver.StmtQueryContext  = (*tracedStmt)(nil)
+	_ driver.NamedValueChecker = (*tracedStmt)(nil)
+)
+
+func (s *tracedStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *tracedStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *tracedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *tracedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	ctx, span := s.driver.startSpan(ctx, "sql.Exec", s.query)
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = s.stmt.Exec(values)
+		}
+	}
+	s.driver.endExec(span, result, err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx, span := s.driver.startSpan(ctx, "sql.Query", s.query)
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.stmt.Query(values)
+		}
+	}
+	return s.driver.endQuery(span, rows, err)
+}
+
+func (s *tracedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type tracedTx struct {
+	tx     driver.Tx
+	ctx    context.Context
+	driver *tracedDriver
+}
+
+func (t *tracedTx) Commit() (err error) {
+	_, span := t.driver.startSpan(t.ctx, "sql.Commit", "")
+	defer func() { t.driver.endSpan(span, err) }()
+	return t.tx.Commit()
+}
+
+func (t *tracedTx) Rollback() (err error) {
+	_, span := t.driver.startSpan(t.ctx, "sql.Rollback", "")
+	defer func() { t.driver.endSpan(span, err) }()
+	return t.tx.Rollback()
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("database: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
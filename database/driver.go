@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	apw_tracing "github.com/kyon1313/observability/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const rowsAffectedKey = attribute.Key("db.rows_affected")
+
+// Open opens a database whose driver is wrapped so that every Query, Exec, Prepare,
+// Begin, Commit and Rollback gets its own span. dbSystem is recorded as db.system,
+// e.g. "postgresql" or "mysql".
+func Open(tracing apw_tracing.OtelTracing, driverName, dsn, dbSystem string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Only used to reach the underlying driver; sql.Open does not connect.
+	defer db.Close()
+
+	wrapped := WrapDriver(db.Driver(), tracing, dbSystem)
+	if dc, ok := wrapped.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+	}
+	return sql.OpenDB(&dsnConnector{dsn: dsn, driver: wrapped}), nil
+}
+
+// WrapDriver returns a driver.Driver that traces all operations of d.
+func WrapDriver(d driver.Driver, tracing apw_tracing.OtelTracing, dbSystem string) driver.Driver {
+	td := &tracedDriver{driver: d, tracing: tracing, system: semconv.DBSystemKey.String(dbSystem)}
+	if _, ok := d.(driver.DriverContext); ok {
+		return &tracedDriverContext{tracedDriver: td}
+	}
+	return td
+}
+
+// WrapConnector returns a driver.Connector that traces all operations of the connections it opens.
+func WrapConnector(c driver.Connector, tracing apw_tracing.OtelTracing, dbSystem string) driver.Connector {
+	td := &tracedDriver{driver: c.Driver(), tracing: tracing, system: semconv.DBSystemKey.String(dbSystem)}
+	return &tracedConnector{connector: c, driver: td}
+}
+
+type tracedDriver struct {
+	driver  driver.Driver
+	tracing apw_tracing.OtelTracing
+	system  attribute.KeyValue
+}
+
+func (d *tracedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn: conn, driver: d}, nil
+}
+
+type tracedDriverContext struct {
+	*tracedDriver
+}
+
+func (d *tracedDriverContext) OpenConnector(name string) (driver.Connector, error) {
+	connector, err := d.driver.(driver.DriverContext).OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConnector{connector: connector, driver: d.tracedDriver}, nil
+}
+
+type tracedConnector struct {
+	connector driver.Connector
+	driver    *tracedDriver
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn: conn, driver: c.driver}, nil
+}
+
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector opens connections of a driver without driver.DriverContext, so that Open
+// needs no globally registered driver name.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// startSpan starts a client span carrying db.system and, if given, the sanitized statement.
+func (d *tracedDriver) startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{d.system}
+	if query != "" {
+		attrs = append(attrs, semconv.DBStatement(SanitizeQuery(query)))
+	}
+	return d.tracing.StartSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan ends the span, treating driver.ErrSkip as a fallback rather than a failure.
+func (d *tracedDriver) endSpan(span trace.Span, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		err = nil
+	}
+	d.tracing.EndSpan(span, err)
+}
+
+// endExec records the rows affected by a successful Exec before ending its span.
+func (d *tracedDriver) endExec(span trace.Span, result driver.Result, err error) {
+	if err == nil && result != nil {
+		if rows, rowsErr := result.RowsAffected(); rowsErr == nil {
+			span.SetAttributes(rowsAffectedKey.Int64(rows))
+		}
+	}
+	d.endSpan(span, err)
+}
+
+// endQuery ends the span of a failed query, or hands it to the returned rows so that it
+// covers their iteration and ends when they are closed.
+func (d *tracedDriver) endQuery(span trace.Span, rows driver.Rows, err error) (driver.Rows, error) {
+	if err != nil {
+		d.endSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{rows: rows, span: span, driver: d}, nil
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+	apw_tracing "github.com/kyon1313/observability/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestQuerySpanEndsWhenRowsAreClosed(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{rows: [][]driver.Value{{int64(1)}, {int64(2)}}})
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM users WHERE name = 'bob' AND age > 30")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	if ended := recorder.Ended(); len(ended) != 0 {
+		t.Fatalf("spans ended before the rows were read: %v", spanNames(ended))
+	}
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("read %d rows, want 2", n)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "sql.Query" {
+		t.Fatalf("ended spans = %v, want [sql.Query]", spanNames(ended))
+	}
+	span := ended[0]
+	if statement, _ := spanAttribute(span, semconv.DBStatementKey); statement.AsString() != "SELECT id FROM users WHERE name = ? AND age > ?" {
+		t.Errorf("db.statement = %q", statement.AsString())
+	}
+	if system, _ := spanAttribute(span, semconv.DBSystemKey); system.AsString() != "fakedb" {
+		t.Errorf("db.system = %q, want fakedb", system.AsString())
+	}
+	if returned, _ := spanAttribute(span, rowsReturnedKey); returned.AsInt64() != 2 {
+		t.Errorf("db.rows_returned = %d, want 2", returned.AsInt64())
+	}
+	if span.Status().Code != codes.Ok {
+		t.Errorf("status = %v, want Ok", span.Status().Code)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{queryErr: errFake})
+
+	if _, err := db.QueryContext(context.Background(), "SELECT 1"); !errors.Is(err, errFake) {
+		t.Fatalf("QueryContext error = %v, want %v", err, errFake)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("ended spans = %v, want one", spanNames(ended))
+	}
+	if ended[0].Status().Code != codes.Error {
+		t.Errorf("status = %v, want Error", ended[0].Status().Code)
+	}
+	if n := exceptionEvents(ended[0]); n != 1 {
+		t.Errorf("exception events = %d, want 1", n)
+	}
+}
+
+func TestRowsError(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{rows: [][]driver.Value{{int64(1)}}, rowsErr: errFake})
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	for rows.Next() {
+	}
+	if !errors.Is(rows.Err(), errFake) {
+		t.Fatalf("rows.Err() = %v, want %v", rows.Err(), errFake)
+	}
+	rows.Close()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error {
+		t.Fatalf("want one failed sql.Query span, got %v", spanNames(ended))
+	}
+	if returned, _ := spanAttribute(ended[0], rowsReturnedKey); returned.AsInt64() != 1 {
+		t.Errorf("db.rows_returned = %d, want 1", returned.AsInt64())
+	}
+}
+
+func TestExec(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{})
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE users SET name = 'alice' WHERE id = 7"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "sql.Exec" {
+		t.Fatalf("ended spans = %v, want [sql.Exec]", spanNames(ended))
+	}
+	if affected, _ := spanAttribute(ended[0], rowsAffectedKey); affected.AsInt64() != 3 {
+		t.Errorf("db.rows_affected = %d, want 3", affected.AsInt64())
+	}
+	if statement, _ := spanAttribute(ended[0], semconv.DBStatementKey); statement.AsString() != "UPDATE users SET name = ? WHERE id = ?" {
+		t.Errorf("db.statement = %q", statement.AsString())
+	}
+}
+
+func TestExecError(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{execErr: errFake})
+
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM users"); !errors.Is(err, errFake) {
+		t.Fatalf("ExecContext error = %v, want %v", err, errFake)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error {
+		t.Fatalf("want one failed sql.Exec span, got %v", spanNames(ended))
+	}
+	if _, ok := spanAttribute(ended[0], rowsAffectedKey); ok {
+		t.Error("db.rows_affected recorded for a failed Exec")
+	}
+}
+
+func TestPreparedStatementAndTransaction(t *testing.T) {
+	db, recorder := openFake(t, &fakeDriver{})
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO users (name) VALUES ($1)")
+	if err != nil {
+		t.Fatalf("PrepareContext: %v", err)
+	}
+	if _, err := stmt.ExecContext(ctx, "carol"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	want := []string{"sql.Begin", "sql.Prepare", "sql.Exec", "sql.Commit"}
+	got := spanNames(recorder.Ended())
+	if len(got) != len(want) {
+		t.Fatalf("ended spans = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ended spans = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOpenWrapsRegisteredDriver(t *testing.T) {
+	db, err := Open(nil, "fake-open", "", "fakedb")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	if _, ok := db.Driver().(*tracedDriver); !ok {
+		t.Fatalf("driver = %T, want *tracedDriver", db.Driver())
+	}
+}
+
+func TestOpenKeepsTracingPerDatabase(t *testing.T) {
+	first, second := tracetest.NewSpanRecorder(), tracetest.NewSpanRecorder()
+	for _, recorder := range []*tracetest.SpanRecorder{first, second} {
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		tracing := apw_tracing.NewTracing(provider.Tracer("database_test"), apw_logging.NewOtelLogging())
+
+		db, err := Open(tracing, "fake-open", "", "fakedb")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if _, err := db.ExecContext(context.Background(), "DELETE FROM sessions"); err != nil {
+			t.Fatalf("ExecContext: %v", err)
+		}
+		db.Close()
+	}
+
+	for i, recorder := range []*tracetest.SpanRecorder{first, second} {
+		if got := spanNames(recorder.Ended()); len(got) != 1 || got[0] != "sql.Exec" {
+			t.Errorf("database %d spans = %v, want [sql.Exec]", i+1, got)
+		}
+	}
+}
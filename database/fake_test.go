@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+	apw_tracing "github.com/kyon1313/observability/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func init() {
+	sql.Register("fake-open", &fakeDriver{})
+}
+
+// fakeDriver is an in-memory driver returning rows and failing with
+// queryErr, execErr or rowsErr when they are set.
+type fakeDriver struct {
+	rows     [][]driver.Value
+	queryErr error
+	execErr  error
+	rowsErr  error
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConnector struct {
+	driver *fakeDriver
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{driver: c.driver}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	if c.driver.queryErr != nil {
+		return nil, c.driver.queryErr
+	}
+	return &fakeRows{values: c.driver.rows, err: c.driver.rowsErr}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	if c.driver.execErr != nil {
+		return nil, c.driver.execErr
+	}
+	return driver.RowsAffected(3), nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, valuesToNamedValues(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, valuesToNamedValues(args))
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	values [][]driver.Value
+	err    error
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// openFake opens a traced database over d and returns the recorder of its spans.
+func openFake(t *testing.T, d *fakeDriver) (*sql.DB, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing := apw_tracing.NewTracing(provider.Tracer("database_test"), apw_logging.NewOtelLogging())
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{driver: d}, tracing, "fakedb"))
+	t.Cleanup(func() { db.Close() })
+	return db, recorder
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name()
+	}
+	return names
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func exceptionEvents(span sdktrace.ReadOnlySpan) int {
+	n := 0
+	for _, event := range span.Events() {
+		if event.Name == "exception" {
+			n++
+		}
+	}
+	return n
+}
+
+var errFake = errors.New("fake failure")
@@ -0,0 +1,89 @@
+package database
+
+import "strings"
+
+// SanitizeQuery replaces string and numeric literals in query with "?" so that
+// statements can be recorded as db.statement without leaking values.
+// Quoted identifiers and bind placeholders such as $1, :name or @p1 are kept.
+func SanitizeQuery(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			i = skipString(query, i)
+			sb.WriteByte('?')
+		case (ch == 'x' || ch == 'X' || ch == 'b' || ch == 'B') && i+1 < len(query) && query[i+1] == '\'' &&
+			(i == 0 || !isIdentByte(query[i-1])):
+			// Hex and bit string literals such as X'1F' and B'101'.
+			i = skipString(query, i+1)
+			sb.WriteByte('?')
+		case ch == '0' && i+1 < len(query) && (query[i+1] == 'x' || query[i+1] == 'X') &&
+			(i == 0 || !isIdentByte(query[i-1])):
+			// Hex literals such as 0x1F.
+			i += 2
+			for i < len(query) && isHexDigit(query[i]) {
+				i++
+			}
+			sb.WriteByte('?')
+		case ch == '"' || ch == '`':
+			// Quoted identifiers are copied verbatim.
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 2
+		case isDigit(ch) && (i == 0 || !isIdentByte(query[i-1])):
+			for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+			sb.WriteByte('?')
+		case isIdentByte(ch) || ch == '$' || ch == ':' || ch == '@':
+			// Copy identifiers and placeholders whole so their digits are kept.
+			start := i
+			i++
+			for i < len(query) && isIdentByte(query[i]) {
+				i++
+			}
+			sb.WriteString(query[start:i])
+		default:
+			sb.WriteByte(ch)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// skipString returns the index just past the string literal opening at query[i],
+// honouring doubled quotes and backslash escapes.
+func skipString(query string, i int) int {
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func isIdentByte(ch byte) bool {
+	return ch == '_' || isDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
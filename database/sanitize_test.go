@@ -0,0 +1,28 @@
+package database
+
+import "testing"
+
+func TestSanitizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT * FROM users WHERE name = 'o''brien'", "SELECT * FROM users WHERE name = ?"},
+		{"SELECT price * 1.5 FROM items", "SELECT price * ? FROM items"},
+		{"SELECT * FROM t2 WHERE c1 = $1 AND c2 = :name AND c3 = @p1", "SELECT * FROM t2 WHERE c1 = $1 AND c2 = :name AND c3 = @p1"},
+		{`SELECT "col 1", ` + "`col2`" + ` FROM t WHERE x = 'a'`, `SELECT "col 1", ` + "`col2`" + ` FROM t WHERE x = ?`},
+		{"INSERT INTO t VALUES (1, 'a', 2.0)", "INSERT INTO t VALUES (?, ?, ?)"},
+		{"SELECT 'unterminated", "SELECT ?"},
+		{`SELECT * FROM t WHERE a = 'it\'s secret' AND b = 'c:\\'`, "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"SELECT * FROM t WHERE k = 0x1F AND m = 0XdeadBEEF", "SELECT * FROM t WHERE k = ? AND m = ?"},
+		{"SELECT * FROM t WHERE k = X'1F' AND m = x'ab' AND f = B'101'", "SELECT * FROM t WHERE k = ? AND m = ? AND f = ?"},
+		{"SELECT max FROM tbox WHERE x0 = 1", "SELECT max FROM tbox WHERE x0 = ?"},
+		{`SELECT "unterminated`, `SELECT "unterminated`},
+	}
+	for _, tt := range tests {
+		if got := SanitizeQuery(tt.query); got != tt.want {
+			t.Errorf("SanitizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsCollector exposes the connection pool statistics of a *sql.DB to Prometheus.
+// Values are read from db.Stats() on every scrape.
+type StatsCollector struct {
+	db *sql.DB
+
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// NewStatsCollector creates a collector for db; dbName is attached as the db_name label.
+func NewStatsCollector(db *sql.DB, dbName string) *StatsCollector {
+	labels := prometheus.Labels{"db_name": dbName}
+	return &StatsCollector{
+		db:           db,
+		maxOpen:      prometheus.NewDesc("db_max_open_connections", "Maximum number of open connections to the database", nil, labels),
+		open:         prometheus.NewDesc("db_open_connections", "The number of established connections both in use and idle", nil, labels),
+		inUse:        prometheus.NewDesc("db_in_use_connections", "The number of connections currently in use", nil, labels),
+		idle:         prometheus.NewDesc("db_idle_connections", "The number of idle connections", nil, labels),
+		waitCount:    prometheus.NewDesc("db_wait_count", "The total number of connections waited for", nil, labels),
+		waitDuration: prometheus.NewDesc("db_wait_duration_seconds", "The total time blocked waiting for a new connection in seconds", nil, labels),
+	}
+}
+
+// RegisterStats registers a StatsCollector for db on reg, or the default registry if reg is nil.
+func RegisterStats(reg prometheus.Registerer, db *sql.DB, dbName string) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(NewStatsCollector(db, dbName))
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
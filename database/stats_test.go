@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestStatsCollector(t *testing.T) {
+	db, _ := openFake(t, &fakeDriver{})
+	db.SetMaxOpenConns(5)
+
+	reg := prometheus.NewRegistry()
+	if err := RegisterStats(reg, db, "users"); err != nil {
+		t.Fatalf("RegisterStats: %v", err)
+	}
+	if err := RegisterStats(reg, db, "users"); err == nil {
+		t.Error("registering the same database twice succeeded")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	want := map[string]dto.MetricType{
+		"db_max_open_connections":  dto.MetricType_GAUGE,
+		"db_open_connections":      dto.MetricType_GAUGE,
+		"db_in_use_connections":    dto.MetricType_GAUGE,
+		"db_idle_connections":      dto.MetricType_GAUGE,
+		"db_wait_count":            dto.MetricType_COUNTER,
+		"db_wait_duration_seconds": dto.MetricType_COUNTER,
+	}
+	for _, family := range families {
+		wantType, ok := want[family.GetName()]
+		if !ok {
+			t.Errorf("unexpected metric %s", family.GetName())
+			continue
+		}
+		delete(want, family.GetName())
+
+		if family.GetType() != wantType {
+			t.Errorf("%s type = %v, want %v", family.GetName(), family.GetType(), wantType)
+		}
+		labels := family.GetMetric()[0].GetLabel()
+		if len(labels) != 1 || labels[0].GetName() != "db_name" || labels[0].GetValue() != "users" {
+			t.Errorf("%s labels = %v, want db_name=users", family.GetName(), labels)
+		}
+		if family.GetName() == "db_max_open_connections" && family.GetMetric()[0].GetGauge().GetValue() != 5 {
+			t.Errorf("db_max_open_connections = %v, want 5", family.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+	for name := range want {
+		t.Errorf("missing metric %s", name)
+	}
+}
@@ -0,0 +1,175 @@
+package apw_errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Category classifies an error for status mapping, metrics and client responses.
+type Category string
+
+const (
+	NotFound   Category = "not_found"
+	Validation Category = "validation"
+	Upstream   Category = "upstream"
+	Internal   Category = "internal"
+)
+
+// Sentinels for errors.Is checks by category, e.g. errors.Is(err, apw_errors.ErrNotFound).
+// They are immutable values, so they can be shared and returned as is.
+var (
+	ErrNotFound   error = sentinel(NotFound)
+	ErrValidation error = sentinel(Validation)
+	ErrUpstream   error = sentinel(Upstream)
+	ErrInternal   error = sentinel(Internal)
+)
+
+// sentinel is the error value of a category.
+type sentinel Category
+
+func (s sentinel) Error() string {
+	return string(s)
+}
+
+// Expected reports whether the category describes a caller mistake, such as a missing
+// resource or invalid input, rather than a failure of the service.
+func (c Category) Expected() bool {
+	return c == NotFound || c == Validation
+}
+
+const maxStackDepth = 32
+
+// Field is a key/value pair attached to an Error.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Error is an error enriched with telemetry context: a category and optional code,
+// key/value fields, the stack where it was created and the trace and span it happened in.
+type Error struct {
+	Category Category
+	Code     string
+	Message  string
+	Fields   []Field
+	TraceID  string
+	SpanID   string
+
+	cause error
+	stack []uintptr
+}
+
+// New creates an Error in category, capturing the stack and the span in ctx.
+// kv is an optional list of alternating keys and values.
+func New(ctx context.Context, category Category, message string, kv ...any) *Error {
+	return newError(ctx, category, message, nil, kv)
+}
+
+// Wrap wraps err in an Error in category. It returns nil if err is nil.
+func Wrap(ctx context.Context, err error, category Category, message string, kv ...any) *Error {
+	if err == nil {
+		return nil
+	}
+	return newError(ctx, category, message, err, kv)
+}
+
+func newError(ctx context.Context, category Category, message string, cause error, kv []any) *Error {
+	e := &Error{Category: category, Message: message, cause: cause}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.TraceID = sc.TraceID().String()
+		e.SpanID = sc.SpanID().String()
+	}
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.Fields = append(e.Fields, Field{Key: fmt.Sprint(kv[i]), Value: kv[i+1]})
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	e.stack = pcs[:runtime.Callers(3, pcs)]
+	return e
+}
+
+// WithCode returns a copy of e with an application-specific error code.
+func (e *Error) WithCode(code string) *Error {
+	c := *e
+	c.Code = code
+	return &c
+}
+
+// With returns a copy of e with an additional key/value field.
+func (e *Error) With(key string, value any) *Error {
+	c := *e
+	c.Fields = append(append([]Field(nil), e.Fields...), Field{Key: key, Value: value})
+	return &c
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = string(e.Category)
+	}
+	if e.cause != nil {
+		return msg + ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches the sentinel of its category, or another *Error with the same category
+// and, if the target has one, the same code.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case sentinel:
+		return Category(t) == e.Category
+	case *Error:
+		return t.Category == e.Category && (t.Code == "" || t.Code == e.Code)
+	default:
+		return false
+	}
+}
+
+// StackTrace formats the stack captured when the error was created.
+func (e *Error) StackTrace() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// As returns the outermost *Error in err's chain.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// CategoryOf returns the category of the outermost *Error or sentinel in err's chain, or Internal.
+func CategoryOf(err error) Category {
+	if e, ok := As(err); ok {
+		return e.Category
+	}
+	var s sentinel
+	if errors.As(err, &s) {
+		return Category(s)
+	}
+	return Internal
+}
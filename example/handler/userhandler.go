@@ -1,39 +1,47 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/kyon1313/observability/example/model"
+	"github.com/kyon1313/observability/example/queue"
 	"github.com/kyon1313/observability/example/service"
+	"github.com/kyon1313/observability/otelBuilder"
 	apw_tracing "github.com/kyon1313/observability/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type UserHandler struct {
 	service service.UserService
 	tracer  apw_tracing.OtelTracing
+	events  *queue.ChannelQueue
 }
 
-func NewUserHandler(service service.UserService, tracer apw_tracing.OtelTracing) *UserHandler {
-	return &UserHandler{service: service, tracer: tracer}
+func NewUserHandler(service service.UserService, tracer apw_tracing.OtelTracing, events *queue.ChannelQueue) *UserHandler {
+	return &UserHandler{service: service, tracer: tracer, events: events}
 }
 
 func (h *UserHandler) GetUser(ctx *gin.Context) {
-
-	var (
-		userName = ctx.Query("name")
-		err      error
-		response any
-		c        = ctx.Request.Context()
-	)
-
-	c, span := h.tracer.StartSpan(c, "handler.GetUser")
-
-	response, err = h.service.GetUser(c, userName)
+	userName := ctx.Query("name")
+
+	response, err := apw_tracing.Trace(ctx.Request.Context(), h.tracer, "handler.GetUser", func(c context.Context) (*model.User, error) {
+		user, err := h.service.GetUser(c, userName)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := h.events.Publish(c, gin.H{"event": "user.viewed", "name": userName}); err != nil {
+			h.tracer.AddEvent(h.tracer.SpanFromContext(c), "handler.GetUser.PublishFailed", attribute.String("error", err.Error()))
+		}
+		return user, nil
+	})
 	if err != nil {
-		h.tracer.LogTrace(span, &err, "service.GetUser.Error", response)()
+		otelBuilder.AbortWithProblem(ctx, err)
 		return
 	}
-	h.tracer.LogTrace(span, &err, "service.Success.Error", response)()
+
 	ctx.JSON(http.StatusOK, response)
 }
@@ -1,19 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
+	"net/http"
 	"time"
 
 	"github.com/kyon1313/observability/example/handler"
+	"github.com/kyon1313/observability/example/queue"
 	"github.com/kyon1313/observability/example/repo"
 	"github.com/kyon1313/observability/example/service"
 	apw_logging "github.com/kyon1313/observability/logs"
 	"github.com/kyon1313/observability/metrics"
 	"github.com/kyon1313/observability/otelBuilder"
+	"github.com/kyon1313/observability/slo"
+	apw_tenant "github.com/kyon1313/observability/tenant"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -25,6 +29,10 @@ const (
 	errorSourceKey    = "error.source"
 )
 
+//go:generate go run github.com/kyon1313/observability/cmd/slorules -config slo.yml -output ../prometheus/slo.rules.yml
+//go:embed slo.yml
+var sloConfig []byte
+
 var otelConfig = initOtel()
 
 func initOtel() *otelBuilder.Otel {
@@ -40,6 +48,9 @@ func initOtel() *otelBuilder.Otel {
 		WithInsecure(true).
 		WithServiceName("testing-api").
 		WithTraceBatchSpanProcessorOption(batchOpts...).
+		WithBaggageAttributes(apw_tenant.BaggageKey).
+		WithSpanMetrics(nil, metrics.WithSpanDimension(apw_tenant.BaggageKey, 50)).
+		WithPrometheusMeter(nil).
 		Build(ctx, l)
 
 	if err != nil {
@@ -54,24 +65,65 @@ func main() {
 	tracerProvider := otel.GetTracerProvider()
 	tracer := tracerProvider.Tracer("apw-test")
 
-	userrepo := repo.NewUserRepository(otelConfig.Tracing)
-	userservice := service.NewUserService(userrepo, otelConfig.Tracing)
-	userhandler := handler.NewUserHandler(userservice, otelConfig.Tracing)
-
-	metricBuilder := metrics.NewMetricsBuilder().
-		AddCounter("http_requests_total", "Total number of HTTP requests", []string{"path"}).
-		AddHistogram("http_request_duration_seconds", "Duration of HTTP requests in seconds", prometheus.DefBuckets, []string{"path"}).
-		AddCounter("http_errors_total", "Total number of HTTP errors", []string{"path"}).
-		AddGauge("active_sessions", "The current number of active sessions", []string{"path"}).
+	metricBuilder, err := metrics.NewMetricsBuilder(metrics.WithStandardCollectors(), metrics.WithStandardNamespace("example")).
 		AddGauge("queue_size", "The current size of the queue", []string{"path"}).
+		AddMethodMetrics().
+		AddErrorMetrics().
 		Build()
+	if err != nil {
+		otelConfig.Logs.Fatal("Failed to register metrics: ", err)
+	}
 
-	r := gin.Default()
+	userrepo := repo.NewTracedUserRepository(repo.NewUserRepository(otelConfig.Tracing), otelConfig.Tracing, metricBuilder)
+	userservice := service.NewTracedUserService(service.NewUserService(userrepo, otelConfig.Tracing), otelConfig.Tracing, metricBuilder)
+	userEvents := queue.NewChannelQueue("user-events", 100, otelConfig.Tracing)
+	userhandler := handler.NewUserHandler(userservice, otelConfig.Tracing, userEvents)
 
-	metricsMiddleware := metrics.NewMetricsMiddlewareDecorator(metricBuilder)
-	r.Use(metricsMiddleware.Middleware(), otelBuilder.TracingMiddleware(otelConfig.Logs, tracer))
+	go userEvents.ConsumeBatch(context.Background(), 10, func(ctx context.Context, msgs []queue.Message) error {
+		for _, msg := range msgs {
+			otelConfig.Logs.Infof("user event received: %s", msg.Body)
+		}
+		return nil
+	})
 
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	slos, err := slo.Load(bytes.NewReader(sloConfig))
+	if err != nil {
+		otelConfig.Logs.Fatal("Failed to load SLOs: ", err)
+	}
+	sloTracker, err := slo.NewTracker(nil, slos...)
+	if err != nil {
+		otelConfig.Logs.Fatal("Failed to register SLO metrics: ", err)
+	}
+
+	r := gin.Default()
+
+	metricsMiddleware, err := metrics.NewHTTPMetricsMiddleware(metrics.HTTPMetricsConfig{
+		Labels:     []metrics.HTTPLabel{metrics.LabelMethod, metrics.LabelRoute, metrics.LabelStatusClass, metrics.LabelTenant},
+		MaxTenants: 50,
+	})
+	if err != nil {
+		otelConfig.Logs.Fatal("Failed to register HTTP metrics: ", err)
+	}
+	r.Use(
+		metricsMiddleware.Middleware(),
+		sloTracker.Middleware(),
+		otelBuilder.TenantMiddleware(otelConfig.Logs, otelBuilder.FirstTenant(
+			otelBuilder.TenantFromHeader("X-Tenant-Id"),
+			otelBuilder.TenantFromJWTClaim("tenant_id"),
+		)),
+		otelBuilder.TracingMiddleware(otelConfig.Logs, tracer),
+		otelBuilder.ProblemMiddleware(otelConfig.Logs, metricBuilder),
+	)
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/metrics/dashboard", func(c *gin.Context) {
+		dashboard, err := metricBuilder.Dashboard("apw-test")
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", dashboard)
+	})
 	r.GET("/user", userhandler.GetUser)
 
 	r.Run(":8080")
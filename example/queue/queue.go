@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	apw_tracing "github.com/kyon1313/observability/tracing"
+
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+const messagingSystem = "channel"
+
+// ErrQueueFull is returned by Publish when the queue has no room for the message.
+var ErrQueueFull = errors.New("queue: full")
+
+type Message struct {
+	Headers []apw_tracing.HeaderPair
+	Body    []byte
+}
+
+// ChannelQueue is an in-memory queue that carries trace context in message headers,
+// standing in for a real broker.
+type ChannelQueue struct {
+	name   string
+	ch     chan Message
+	tracer apw_tracing.OtelTracing
+}
+
+func NewChannelQueue(name string, size int, tracer apw_tracing.OtelTracing) *ChannelQueue {
+	return &ChannelQueue{name: name, ch: make(chan Message, size), tracer: tracer}
+}
+
+// Publish enqueues payload without blocking, failing with ErrQueueFull when the queue is full
+// so that callers on a request path are never held up by a slow consumer.
+func (q *ChannelQueue) Publish(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	msg := Message{Body: body}
+	_, span := q.tracer.StartProducerSpan(ctx, messagingSystem, q.name, &apw_tracing.PairsCarrier{Headers: &msg.Headers},
+		semconv.MessagingMessagePayloadSizeBytes(len(body)))
+	defer q.tracer.LogTrace(span, &err, "queue.Publish", nil)()
+
+	select {
+	case q.ch <- msg:
+	default:
+		err = ErrQueueFull
+	}
+	return err
+}
+
+// Consume hands every message to handle inside a consumer span until ctx is done.
+func (q *ChannelQueue) Consume(ctx context.Context, handle func(ctx context.Context, msg Message) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.ch:
+			q.process(ctx, []Message{msg}, func(ctx context.Context, msgs []Message) error {
+				return handle(ctx, msgs[0])
+			})
+		}
+	}
+}
+
+// ConsumeBatch drains up to size buffered messages at a time and processes them in one
+// span that links back to each message's origin trace.
+func (q *ChannelQueue) ConsumeBatch(ctx context.Context, size int, handle func(ctx context.Context, msgs []Message) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.ch:
+			batch := []Message{msg}
+		drain:
+			for len(batch) < size {
+				select {
+				case msg := <-q.ch:
+					batch = append(batch, msg)
+				default:
+					break drain
+				}
+			}
+			q.process(ctx, batch, handle)
+		}
+	}
+}
+
+func (q *ChannelQueue) process(ctx context.Context, msgs []Message, handle func(ctx context.Context, msgs []Message) error) {
+	carriers := make([]propagation.TextMapCarrier, len(msgs))
+	for i := range msgs {
+		carriers[i] = &apw_tracing.PairsCarrier{Headers: &msgs[i].Headers}
+	}
+
+	ctx, span := q.tracer.StartConsumerSpan(ctx, messagingSystem, q.name, carriers)
+	err := handle(ctx, msgs)
+	q.tracer.LogTrace(span, &err, "queue.Consume", nil)()
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 
+	apw_errors "github.com/kyon1313/observability/errors"
 	"github.com/kyon1313/observability/example/model"
 	apw_tracing "github.com/kyon1313/observability/tracing"
 )
 
+//go:generate go run github.com/kyon1313/observability/cmd/tracegen -type UserRepository
+
 type UserRepository interface {
 	GetUserByName(ctx context.Context, name string) (*model.User, error)
 }
@@ -21,24 +24,11 @@ func NewUserRepository(tracer apw_tracing.OtelTracing) UserRepository {
 }
 
 func (r *userRepository) GetUserByName(ctx context.Context, name string) (*model.User, error) {
-	var (
-		err      error
-		response *model.User
-	)
-
-	_, span := r.tracer.StartSpan(ctx, "repo.GetUser")
-
 	for _, user := range model.Users {
 		if user.Name == name {
-
-			response = &user
-			r.tracer.LogTrace(span, &err, "repo.GetUser.Success", response)()
+			user := user
 			return &user, nil
 		}
 	}
-
-	err = fmt.Errorf("name:%s user not found", name)
-	r.tracer.LogTrace(span, &err, "repo.GetUser.Error", response)()
-
-	return nil, err
+	return nil, apw_errors.New(ctx, apw_errors.NotFound, fmt.Sprintf("name:%s user not found", name), "user.name", name)
 }
@@ -0,0 +1,38 @@
+// Code generated by tracegen. DO NOT EDIT.
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyon1313/observability/example/model"
+	"github.com/kyon1313/observability/metrics"
+	apw_tracing "github.com/kyon1313/observability/tracing"
+)
+
+type tracedUserRepository struct {
+	next    UserRepository
+	tracer  apw_tracing.OtelTracing
+	metrics *metrics.Metrics
+}
+
+// NewTracedUserRepository wraps next so that every context-aware call is traced and timed.
+func NewTracedUserRepository(next UserRepository, tracer apw_tracing.OtelTracing, m *metrics.Metrics) UserRepository {
+	return &tracedUserRepository{next: next, tracer: tracer, metrics: m}
+}
+
+func (d *tracedUserRepository) GetUserByName(ctx context.Context, name string) (r0 *model.User, err error) {
+	ctx, span := d.tracer.StartSpan(ctx, "repo.UserRepository.GetUserByName")
+	start := time.Now()
+	d.tracer.AddAttribute(span, "arg.name", name)
+	defer func() {
+		metrics.ObserveMethodDuration(d.metrics, "repo.UserRepository.GetUserByName", start, err)
+		if err != nil {
+			d.tracer.AddAttribute(span, "error.source", "repo.UserRepository.GetUserByName")
+		}
+		d.tracer.EndSpan(span, err)
+	}()
+
+	return d.next.GetUserByName(ctx, name)
+}
@@ -8,6 +8,8 @@ import (
 	apw_tracing "github.com/kyon1313/observability/tracing"
 )
 
+//go:generate go run github.com/kyon1313/observability/cmd/tracegen -type UserService
+
 type UserService interface {
 	GetUser(ctx context.Context, name string) (*model.User, error)
 	Tracer() apw_tracing.OtelTracing
@@ -23,21 +25,7 @@ func NewUserService(repo repo.UserRepository, tracer apw_tracing.OtelTracing) Us
 }
 
 func (s *userService) GetUser(ctx context.Context, name string) (*model.User, error) {
-	var (
-		err      error
-		response *model.User
-	)
-
-	ctx, span := s.tracer.StartSpan(ctx, "service.GetUser")
-
-	response, err = s.repo.GetUserByName(ctx, name)
-	if err != nil {
-		s.tracer.LogTrace(span, &err, "service.GetUser.Error", response)()
-		return nil, err
-	}
-
-	s.tracer.LogTrace(span, &err, "service.GetUser.Success", response)()
-	return response, nil
+	return s.repo.GetUserByName(ctx, name)
 }
 
 func (s *userService) Tracer() apw_tracing.OtelTracing {
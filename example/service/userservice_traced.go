@@ -0,0 +1,42 @@
+// Code generated by tracegen. DO NOT EDIT.
+
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyon1313/observability/example/model"
+	"github.com/kyon1313/observability/metrics"
+	apw_tracing "github.com/kyon1313/observability/tracing"
+)
+
+type tracedUserService struct {
+	next    UserService
+	tracer  apw_tracing.OtelTracing
+	metrics *metrics.Metrics
+}
+
+// NewTracedUserService wraps next so that every context-aware call is traced and timed.
+func NewTracedUserService(next UserService, tracer apw_tracing.OtelTracing, m *metrics.Metrics) UserService {
+	return &tracedUserService{next: next, tracer: tracer, metrics: m}
+}
+
+func (d *tracedUserService) GetUser(ctx context.Context, name string) (r0 *model.User, err error) {
+	ctx, span := d.tracer.StartSpan(ctx, "service.UserService.GetUser")
+	start := time.Now()
+	d.tracer.AddAttribute(span, "arg.name", name)
+	defer func() {
+		metrics.ObserveMethodDuration(d.metrics, "service.UserService.GetUser", start, err)
+		if err != nil {
+			d.tracer.AddAttribute(span, "error.source", "service.UserService.GetUser")
+		}
+		d.tracer.EndSpan(span, err)
+	}()
+
+	return d.next.GetUser(ctx, name)
+}
+
+func (d *tracedUserService) Tracer() apw_tracing.OtelTracing {
+	return d.next.Tracer()
+}
@@ -0,0 +1,43 @@
+// Package w3cbaggage enforces the W3C Baggage limits that the OpenTelemetry baggage
+// package leaves to callers. It is shared by the tracing and tenant packages.
+package w3cbaggage
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// W3C Baggage propagation limits.
+const (
+	MaxMembers     = 180
+	MaxMemberBytes = 4096
+	MaxBytes       = 8192
+)
+
+var (
+	ErrTooManyMembers = errors.New("baggage: too many members")
+	ErrMemberTooLarge = errors.New("baggage: member too large")
+	ErrTooLarge       = errors.New("baggage: too large")
+)
+
+// SetMember adds member to bag, returning bag unchanged with an error if the member or
+// the result exceeds the W3C limits.
+func SetMember(bag baggage.Baggage, member baggage.Member) (baggage.Baggage, error) {
+	if n := len(member.String()); n > MaxMemberBytes {
+		return bag, fmt.Errorf("%w: %q is %d bytes", ErrMemberTooLarge, member.Key(), n)
+	}
+
+	updated, err := bag.SetMember(member)
+	if err != nil {
+		return bag, fmt.Errorf("baggage member %q: %w", member.Key(), err)
+	}
+	if updated.Len() > MaxMembers {
+		return bag, ErrTooManyMembers
+	}
+	if n := len(updated.String()); n > MaxBytes {
+		return bag, fmt.Errorf("%w: %d bytes", ErrTooLarge, n)
+	}
+	return updated, nil
+}
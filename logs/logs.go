@@ -1,19 +1,33 @@
 package apw_logging
 
 import (
+	"context"
+
+	apw_errors "github.com/kyon1313/observability/errors"
+	apw_tenant "github.com/kyon1313/observability/tenant"
+
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-// OtelLogging defines methods for logging operations.
+// OtelLogging defines methods for logging operations. A context.Context passed to Debug,
+// Info, Warn, Error and the other methods without a template is not printed; like
+// WithContext, it adds the trace_id, span_id and tenant_id it carries to the line. The
+// *w methods accept it in place of a key/value pair or as the value of a key. The *f
+// methods format their arguments as given, so use WithContext with them instead.
 type OtelLogging interface {
 	Debug(args ...interface{})
 	Debugf(template string, args ...interface{})
+	Debugw(msg string, keysAndValues ...interface{})
 	Info(args ...interface{})
 	Infof(template string, args ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
 	Warn(args ...interface{})
 	Warnf(template string, args ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
 	Error(args ...interface{})
 	Errorf(template string, args ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
 	DPanic(args ...interface{})
 	DPanicf(template string, args ...interface{})
 	Panic(args ...interface{})
@@ -21,7 +35,7 @@ type OtelLogging interface {
 	Fatal(args ...interface{})
 	Fatalf(template string, args ...interface{})
 	Logf(template string, args ...interface{})
-	// WithContext(ctx context.Context) OtelLogging
+	WithContext(ctx context.Context) OtelLogging
 }
 
 type otelLog struct {
@@ -38,68 +52,239 @@ func NewOtelLogging() OtelLogging {
 	}
 }
 
+// with returns a logger carrying the fields of every context.Context and apw_errors.Error
+// in args, and args without the contexts.
+func (l *otelLog) with(args []interface{}) (*zap.SugaredLogger, []interface{}) {
+	logger := l.logger
+	rest := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case context.Context:
+			logger = withContext(logger, v)
+			continue
+		case error:
+			logger = withError(logger, v)
+		}
+		rest = append(rest, arg)
+	}
+	return logger, rest
+}
+
+// withf returns a logger carrying the fields of every apw_errors.Error in the arguments
+// of a template. Contexts are left in place so that the arguments stay aligned with the
+// template's verbs.
+func (l *otelLog) withf(args []interface{}) *zap.SugaredLogger {
+	logger := l.logger
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			logger = withError(logger, err)
+		}
+	}
+	return logger
+}
+
+// withKV is with for alternating keys and values. A context.Context is taken from a key
+// position, where it stands alone like a zap.Field, or from the value of a key; a context
+// anywhere else is logged as an ordinary value so that the pairs stay aligned.
+func (l *otelLog) withKV(keysAndValues []interface{}) (*zap.SugaredLogger, []interface{}) {
+	logger := l.logger
+	rest := make([]interface{}, 0, len(keysAndValues))
+	for i := 0; i < len(keysAndValues); i++ {
+		switch key := keysAndValues[i].(type) {
+		case context.Context:
+			logger = withContext(logger, key)
+			continue
+		case zap.Field:
+			rest = append(rest, key)
+			continue
+		case error:
+			logger = withError(logger, key)
+		}
+		if i+1 == len(keysAndValues) {
+			rest = append(rest, keysAndValues[i])
+			break
+		}
+
+		value := keysAndValues[i+1]
+		switch v := value.(type) {
+		case context.Context:
+			logger = withContext(logger, v)
+			i++
+			continue
+		case error:
+			logger = withError(logger, v)
+		}
+		rest = append(rest, keysAndValues[i], value)
+		i++
+	}
+	return logger, rest
+}
+
+// withContext adds the trace_id, span_id and tenant_id found in ctx.
+func withContext(logger *zap.SugaredLogger, ctx context.Context) *zap.SugaredLogger {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+	if tenantID := apw_tenant.FromContext(ctx); tenantID != "" {
+		logger = logger.With(apw_tenant.BaggageKey, tenantID)
+	}
+	return logger
+}
+
+// withError adds the exception.* fields of the apw_errors.Error in err's chain, if any.
+func withError(logger *zap.SugaredLogger, err error) *zap.SugaredLogger {
+	e, ok := apw_errors.As(err)
+	if !ok {
+		return logger
+	}
+
+	fields := []interface{}{"exception.category", string(e.Category)}
+	if e.Code != "" {
+		fields = append(fields, "exception.code", e.Code)
+	}
+	if e.TraceID != "" {
+		fields = append(fields, "exception.trace_id", e.TraceID, "exception.span_id", e.SpanID)
+	}
+	for _, f := range e.Fields {
+		fields = append(fields, "exception."+f.Key, f.Value)
+	}
+	if withStack(e.Category) {
+		fields = append(fields, "exception.stacktrace", e.StackTrace())
+	}
+	return logger.With(fields...)
+}
+
+// withStack reports whether errors of category are logged with their stack trace: only
+// internal and unknown categories are, since the others are not bugs in the service.
+func withStack(category apw_errors.Category) bool {
+	switch category {
+	case apw_errors.NotFound, apw_errors.Validation, apw_errors.Upstream:
+		return false
+	default:
+		return true
+	}
+}
+
+// expected reports whether args carry errors and all of them are in an expected
+// category, such as NotFound or Validation.
+func expected(args []interface{}) bool {
+	found := false
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+		if !apw_errors.CategoryOf(err).Expected() {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
 func (l *otelLog) Debug(args ...interface{}) {
-	l.logger.Debug(args...)
+	logger, args := l.with(args)
+	logger.Debug(args...)
 }
 
 func (l *otelLog) Debugf(template string, args ...interface{}) {
-	l.logger.Debugf(template, args...)
+	l.withf(args).Debugf(template, args...)
+}
+
+func (l *otelLog) Debugw(msg string, keysAndValues ...interface{}) {
+	logger, keysAndValues := l.withKV(keysAndValues)
+	logger.Debugw(msg, keysAndValues...)
 }
 
 func (l *otelLog) Info(args ...interface{}) {
-	l.logger.Info(args...)
+	logger, args := l.with(args)
+	logger.Info(args...)
 }
 
 func (l *otelLog) Infof(template string, args ...interface{}) {
-	l.logger.Infof(template, args...)
+	l.withf(args).Infof(template, args...)
+}
+
+func (l *otelLog) Infow(msg string, keysAndValues ...interface{}) {
+	logger, keysAndValues := l.withKV(keysAndValues)
+	logger.Infow(msg, keysAndValues...)
 }
 
 func (l *otelLog) Warn(args ...interface{}) {
-	l.logger.Warn(args...)
+	logger, args := l.with(args)
+	logger.Warn(args...)
 }
 
 func (l *otelLog) Warnf(template string, args ...interface{}) {
-	l.logger.Warnf(template, args...)
+	l.withf(args).Warnf(template, args...)
 }
 
+func (l *otelLog) Warnw(msg string, keysAndValues ...interface{}) {
+	logger, keysAndValues := l.withKV(keysAndValues)
+	logger.Warnw(msg, keysAndValues...)
+}
+
+// Error logs at error level, or at warn level when every error in args is in an
+// expected category. Errorf and Errorw do the same.
 func (l *otelLog) Error(args ...interface{}) {
-	l.logger.Error(args...)
+	logger, rest := l.with(args)
+	if expected(args) {
+		logger.Warn(rest...)
+		return
+	}
+	logger.Error(rest...)
 }
 
 func (l *otelLog) Errorf(template string, args ...interface{}) {
-	l.logger.Errorf(template, args...)
+	logger := l.withf(args)
+	if expected(args) {
+		logger.Warnf(template, args...)
+		return
+	}
+	logger.Errorf(template, args...)
+}
+
+func (l *otelLog) Errorw(msg string, keysAndValues ...interface{}) {
+	logger, rest := l.withKV(keysAndValues)
+	if expected(keysAndValues) {
+		logger.Warnw(msg, rest...)
+		return
+	}
+	logger.Errorw(msg, rest...)
 }
 
 func (l *otelLog) DPanic(args ...interface{}) {
-	l.logger.DPanic(args...)
+	logger, args := l.with(args)
+	logger.DPanic(args...)
 }
 
 func (l *otelLog) DPanicf(template string, args ...interface{}) {
-	l.logger.DPanicf(template, args...)
+	l.withf(args).DPanicf(template, args...)
 }
 
 func (l *otelLog) Panic(args ...interface{}) {
-	l.logger.Panic(args...)
+	logger, args := l.with(args)
+	logger.Panic(args...)
 }
 
 func (l *otelLog) Panicf(template string, args ...interface{}) {
-	l.logger.Panicf(template, args...)
+	l.withf(args).Panicf(template, args...)
 }
 
 func (l *otelLog) Fatal(args ...interface{}) {
-	l.logger.Fatal(args...)
+	logger, args := l.with(args)
+	logger.Fatal(args...)
 }
 
 func (l *otelLog) Fatalf(template string, args ...interface{}) {
-	l.logger.Fatalf(template, args...)
+	l.withf(args).Fatalf(template, args...)
 }
 
 func (l *otelLog) Logf(template string, args ...interface{}) {
-	l.logger.Infof(template, args...)
+	l.withf(args).Infof(template, args...)
 }
 
-// func (l *otelLog) WithContext(ctx context.Context) OtelLogging {
-// 	return &otelLog{
-// 		logger: l.logger.With(ctx),
-// 	}
-// }
+// WithContext returns a logger carrying the trace_id, span_id and tenant_id found in ctx.
+func (l *otelLog) WithContext(ctx context.Context) OtelLogging {
+	return &otelLog{logger: withContext(l.logger, ctx)}
+}
@@ -0,0 +1,83 @@
+package apw_logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	apw_tenant "github.com/kyon1313/observability/tenant"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newTestLogging returns a logger writing JSON lines to the returned buffer.
+func newTestLogging() (*otelLog, *bytes.Buffer) {
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: zapcore.LowercaseLevelEncoder})
+	core := zapcore.NewCore(encoder, zapcore.AddSync(&buf), zap.DebugLevel)
+	return &otelLog{logger: zap.New(core).Sugar()}, &buf
+}
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestKeysAndValuesStayAligned(t *testing.T) {
+	ctx, _ := apw_tenant.NewContext(context.Background(), "acme")
+	tests := []struct {
+		name          string
+		keysAndValues []interface{}
+		want          map[string]any
+	}{
+		{"context alone", []interface{}{ctx, "k", "v"}, map[string]any{"k": "v", "tenant_id": "acme"}},
+		{"context as a value", []interface{}{"k", "v", "ctx", ctx}, map[string]any{"k": "v", "tenant_id": "acme"}},
+		{"context after the last pair", []interface{}{"k", "v", ctx}, map[string]any{"k": "v", "tenant_id": "acme"}},
+		{"zap field alone", []interface{}{zap.Int("n", 1), "k", "v"}, map[string]any{"k": "v", "n": float64(1)}},
+		{"values in order", []interface{}{"a", 1, "b", "two"}, map[string]any{"a": float64(1), "b": "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, buf := newTestLogging()
+			l.Infow("msg", tt.keysAndValues...)
+
+			line := decodeLine(t, buf)
+			for key, want := range tt.want {
+				if got := line[key]; got != want {
+					t.Errorf("%s = %v, want %v (line %s)", key, got, want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateArgumentsAreNotShifted(t *testing.T) {
+	ctx, _ := apw_tenant.NewContext(context.Background(), "acme")
+	l, buf := newTestLogging()
+	l.WithContext(ctx).Infof("user %s ordered %d items", "bob", 3)
+
+	line := decodeLine(t, buf)
+	if line["msg"] != "user bob ordered 3 items" {
+		t.Errorf("msg = %q, want %q", line["msg"], "user bob ordered 3 items")
+	}
+	if line["tenant_id"] != "acme" {
+		t.Errorf("tenant_id = %v, want acme", line["tenant_id"])
+	}
+}
+
+func TestArgsDropContexts(t *testing.T) {
+	ctx, _ := apw_tenant.NewContext(context.Background(), "acme")
+	l, buf := newTestLogging()
+	l.Info(ctx, "order placed")
+
+	line := decodeLine(t, buf)
+	if line["msg"] != "order placed" || line["tenant_id"] != "acme" {
+		t.Errorf("line = %s, want msg \"order placed\" with tenant_id acme", buf.String())
+	}
+}
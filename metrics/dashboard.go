@@ -0,0 +1,382 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type metricKind int
+
+const (
+	kindCounter metricKind = iota
+	kindHistogram
+	kindGauge
+	kindSummary
+)
+
+var kindRows = []struct {
+	kind  metricKind
+	title string
+}{
+	{kindCounter, "Rates"},
+	{kindHistogram, "Latencies and distributions"},
+	{kindGauge, "Gauges"},
+	{kindSummary, "Summaries"},
+}
+
+// definition describes a metric added to a MetricsBuilder.
+type definition struct {
+	kind   metricKind
+	fqName string
+	help   string
+	labels []string
+	// native is set for histograms that only expose native buckets.
+	native bool
+}
+
+const (
+	dashboardDatasourceUID = "${datasource}"
+	panelWidth             = 12
+	panelHeight            = 8
+)
+
+// DashboardOption configures the dashboard generated by Metrics.Dashboard.
+type DashboardOption func(*dashboardConfig)
+
+type dashboardConfig struct {
+	uid          string
+	serviceLabel string
+	routeLabels  []string
+}
+
+// WithDashboardUID sets the dashboard UID, so re-imports replace the dashboard. Defaults to a slug of the title.
+func WithDashboardUID(uid string) DashboardOption {
+	return func(c *dashboardConfig) {
+		c.uid = uid
+	}
+}
+
+// WithServiceLabel sets the label selected by the service variable. Defaults to job.
+func WithServiceLabel(label string) DashboardOption {
+	return func(c *dashboardConfig) {
+		c.serviceLabel = label
+	}
+}
+
+// WithRouteLabels sets the route labels, each selected by a template variable of the same
+// name. Defaults to route and path.
+func WithRouteLabels(labels ...string) DashboardOption {
+	return func(c *dashboardConfig) {
+		c.routeLabels = labels
+	}
+}
+
+// Dashboard returns a Grafana dashboard JSON model for the metrics added to the builder.
+// Counters are shown as rates, histograms as p50/p90/p99 latencies, gauges as stats or
+// time series and summaries as averages, each summed by the metric's labels. Panels are
+// grouped in rows by metric type and filtered by the service variable and the variable
+// of each route label they have.
+func (m *Metrics) Dashboard(title string, opts ...DashboardOption) ([]byte, error) {
+	cfg := dashboardConfig{
+		uid:          slug(title),
+		serviceLabel: "job",
+		routeLabels:  []string{"route", "path"},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var panels []grafanaPanel
+	y := 0
+	for _, row := range kindRows {
+		var defs []definition
+		for _, def := range m.definitions {
+			if def.kind == row.kind {
+				defs = append(defs, def)
+			}
+		}
+		if len(defs) == 0 {
+			continue
+		}
+
+		panels = append(panels, grafanaPanel{ID: len(panels) + 1, Type: "row", Title: row.title, GridPos: gridPos{H: 1, W: 24, Y: y}})
+		y++
+		for i, def := range defs {
+			panel := cfg.panel(def)
+			panel.ID = len(panels) + 1
+			panel.GridPos = gridPos{H: panelHeight, W: panelWidth, X: (i % 2) * panelWidth, Y: y + (i/2)*panelHeight}
+			panels = append(panels, panel)
+		}
+		y += (len(defs) + 1) / 2 * panelHeight
+	}
+
+	dashboard := grafanaDashboard{
+		UID:           cfg.uid,
+		Title:         title,
+		Tags:          []string{"generated"},
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          timeRange{From: "now-1h", To: "now"},
+		Panels:        panels,
+		Templating:    templating{List: cfg.variables()},
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+func (c *dashboardConfig) panel(def definition) grafanaPanel {
+	selector := c.selector(def)
+	groupBy := strings.Join(def.labels, ", ")
+	legend := legendFormat(def.labels)
+
+	panel := grafanaPanel{
+		Type:        "timeseries",
+		Title:       def.fqName,
+		Description: def.help,
+		Datasource:  &datasourceRef{Type: "prometheus", UID: dashboardDatasourceUID},
+		FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: unitOf(def.fqName)}},
+	}
+
+	switch def.kind {
+	case kindCounter:
+		panel.FieldConfig.Defaults.Unit = "ops"
+		panel.Targets = []grafanaTarget{{
+			Expr:         sumBy(groupBy, fmt.Sprintf("rate(%s%s[$__rate_interval])", def.fqName, selector)),
+			LegendFormat: legend,
+		}}
+	case kindHistogram:
+		// Native histograms are a single series, so the quantile is taken over the
+		// metric itself rather than its per-le _bucket series.
+		buckets := sumBy(strings.Join(append([]string{"le"}, def.labels...), ", "),
+			fmt.Sprintf("rate(%s_bucket%s[$__rate_interval])", def.fqName, selector))
+		if def.native {
+			buckets = sumBy(groupBy, fmt.Sprintf("rate(%s%s[$__rate_interval])", def.fqName, selector))
+		}
+		for _, q := range []struct{ quantile, name string }{{"0.5", "p50"}, {"0.9", "p90"}, {"0.99", "p99"}} {
+			panel.Targets = append(panel.Targets, grafanaTarget{
+				Expr:         fmt.Sprintf("histogram_quantile(%s, %s)", q.quantile, buckets),
+				LegendFormat: strings.TrimSpace(q.name + " " + legend),
+			})
+		}
+	case kindGauge:
+		if len(def.labels) == 0 {
+			panel.Type = "stat"
+		}
+		panel.Targets = []grafanaTarget{{
+			Expr:         sumBy(groupBy, def.fqName+selector),
+			LegendFormat: legend,
+		}}
+	case kindSummary:
+		panel.Title += " (average)"
+		panel.Targets = []grafanaTarget{{
+			Expr: fmt.Sprintf("%s / %s",
+				sumBy(groupBy, fmt.Sprintf("rate(%s_sum%s[$__rate_interval])", def.fqName, selector)),
+				sumBy(groupBy, fmt.Sprintf("rate(%s_count%s[$__rate_interval])", def.fqName, selector))),
+			LegendFormat: legend,
+		}}
+	}
+
+	for i := range panel.Targets {
+		panel.Targets[i].RefID = string(rune('A' + i))
+		panel.Targets[i].Datasource = panel.Datasource
+	}
+	return panel
+}
+
+// selector filters the metric by the service variable and by the variable of each route
+// label it has.
+func (c *dashboardConfig) selector(def definition) string {
+	matchers := []string{fmt.Sprintf(`%s=~"$service"`, c.serviceLabel)}
+	for _, route := range c.routeLabels {
+		for _, label := range def.labels {
+			if label == route {
+				matchers = append(matchers, fmt.Sprintf(`%s=~"$%s"`, route, route))
+			}
+		}
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+func (c *dashboardConfig) variables() []templateVariable {
+	datasource := &datasourceRef{Type: "prometheus", UID: dashboardDatasourceUID}
+	variables := []templateVariable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{
+			Name: "service", Label: "Service", Type: "query", Datasource: datasource,
+			Query:   fmt.Sprintf("label_values(%s)", c.serviceLabel),
+			Refresh: 2, IncludeAll: true, Multi: true, AllValue: ".*",
+		},
+	}
+	for _, route := range c.routeLabels {
+		variables = append(variables, templateVariable{
+			Name: route, Label: route, Type: "query", Datasource: datasource,
+			Query:   fmt.Sprintf("label_values(%s)", route),
+			Refresh: 2, IncludeAll: true, Multi: true, AllValue: ".*",
+		})
+	}
+	return variables
+}
+
+func sumBy(labels, expr string) string {
+	if labels == "" {
+		return fmt.Sprintf("sum(%s)", expr)
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", labels, expr)
+}
+
+func legendFormat(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = "{{" + label + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+func unitOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(name, "_ratio"):
+		return "percentunit"
+	default:
+		return "short"
+	}
+}
+
+func slug(title string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, title), "-")
+}
+
+// GrafanaDatasources returns a Grafana provisioning file declaring the Prometheus data
+// source used by Dashboard. If jaegerURL is set it also declares a Jaeger data source and
+// links the trace_id of Prometheus exemplars to it.
+func GrafanaDatasources(prometheusURL, jaegerURL string) ([]byte, error) {
+	prometheus := provisionedDatasource{
+		Name:      "Prometheus",
+		Type:      "prometheus",
+		UID:       "prometheus",
+		Access:    "proxy",
+		URL:       prometheusURL,
+		IsDefault: true,
+	}
+	datasources := []provisionedDatasource{prometheus}
+	if jaegerURL != "" {
+		datasources[0].JSONData = map[string]any{
+			"exemplarTraceIdDestinations": []map[string]string{{"name": "trace_id", "datasourceUid": "jaeger"}},
+		}
+		datasources = append(datasources, provisionedDatasource{
+			Name:   "Jaeger",
+			Type:   "jaeger",
+			UID:    "jaeger",
+			Access: "proxy",
+			URL:    jaegerURL,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(provisioning{APIVersion: 1, Datasources: datasources}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+type grafanaDashboard struct {
+	UID           string         `json:"uid"`
+	Title         string         `json:"title"`
+	Tags          []string       `json:"tags"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Refresh       string         `json:"refresh"`
+	Time          timeRange      `json:"time"`
+	Templating    templating     `json:"templating"`
+	Panels        []grafanaPanel `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []templateVariable `json:"list"`
+}
+
+type templateVariable struct {
+	Name       string         `json:"name"`
+	Label      string         `json:"label"`
+	Type       string         `json:"type"`
+	Query      string         `json:"query"`
+	Datasource *datasourceRef `json:"datasource,omitempty"`
+	Refresh    int            `json:"refresh,omitempty"`
+	IncludeAll bool           `json:"includeAll,omitempty"`
+	Multi      bool           `json:"multi,omitempty"`
+	AllValue   string         `json:"allValue,omitempty"`
+}
+
+type grafanaPanel struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	GridPos     gridPos         `json:"gridPos"`
+	Datasource  *datasourceRef  `json:"datasource,omitempty"`
+	FieldConfig *fieldConfig    `json:"fieldConfig,omitempty"`
+	Targets     []grafanaTarget `json:"targets,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type datasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type grafanaTarget struct {
+	RefID        string         `json:"refId"`
+	Datasource   *datasourceRef `json:"datasource,omitempty"`
+	Expr         string         `json:"expr"`
+	LegendFormat string         `json:"legendFormat,omitempty"`
+}
+
+type provisioning struct {
+	APIVersion  int                     `yaml:"apiVersion"`
+	Datasources []provisionedDatasource `yaml:"datasources"`
+}
+
+type provisionedDatasource struct {
+	Name      string         `yaml:"name"`
+	Type      string         `yaml:"type"`
+	UID       string         `yaml:"uid"`
+	Access    string         `yaml:"access"`
+	URL       string         `yaml:"url"`
+	IsDefault bool           `yaml:"isDefault,omitempty"`
+	JSONData  map[string]any `yaml:"jsonData,omitempty"`
+}
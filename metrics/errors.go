@@ -0,0 +1,19 @@
+package metrics
+
+const ErrorsTotal = "errors_total"
+
+// AddErrorMetrics registers the counter of errors returned to clients, by route and error category.
+func (b *MetricsBuilder) AddErrorMetrics() *MetricsBuilder {
+	return b.AddCounter(ErrorsTotal, "Total number of errors returned to clients by category", []string{"path", "category"})
+}
+
+// CountError increments the errors counter for path and category.
+// It is a no-op when m is nil or AddErrorMetrics was not called.
+func CountError(m *Metrics, path, category string) {
+	if m == nil {
+		return
+	}
+	if counter, ok := m.Counters[ErrorsTotal]; ok {
+		counter.WithLabelValues(path, category).Inc()
+	}
+}
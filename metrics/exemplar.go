@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler serves the default registry in the OpenMetrics format when the scraper accepts
+// it, which is required for exemplars to be exposed.
+func Handler() http.Handler {
+	return HandlerFor(prometheus.DefaultGatherer)
+}
+
+// HandlerFor serves reg like Handler, for metrics built WithRegisterer(reg).
+func HandlerFor(reg prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// exemplarLabels returns the trace_id and span_id of the sampled span in ctx, or nil.
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()}
+}
+
+// ObserveWithTrace observes value, attaching the sampled span in ctx as an exemplar.
+func ObserveWithTrace(ctx context.Context, observer prometheus.Observer, value float64) {
+	if exemplar := exemplarLabels(ctx); exemplar != nil {
+		if eo, ok := observer.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(value, exemplar)
+			return
+		}
+	}
+	observer.Observe(value)
+}
+
+// IncWithTrace increments counter, attaching the sampled span in ctx as an exemplar.
+func IncWithTrace(ctx context.Context, counter prometheus.Counter) {
+	if exemplar := exemplarLabels(ctx); exemplar != nil {
+		if ea, ok := counter.(prometheus.ExemplarAdder); ok {
+			ea.AddWithExemplar(1, exemplar)
+			return
+		}
+	}
+	counter.Inc()
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	GRPCServerRequestsTotal   = "grpc_server_requests_total"
+	GRPCServerRequestDuration = "grpc_server_request_duration_seconds"
+	GRPCClientRequestsTotal   = "grpc_client_requests_total"
+	GRPCClientRequestDuration = "grpc_client_request_duration_seconds"
+)
+
+// AddGRPCMetrics registers the request counters and latency histograms recorded by the gRPC interceptors.
+func (b *MetricsBuilder) AddGRPCMetrics() *MetricsBuilder {
+	return b.
+		AddCounter(GRPCServerRequestsTotal, "Total number of gRPC requests handled by the server", []string{"service", "method", "code"}).
+		AddHistogram(GRPCServerRequestDuration, "Duration of gRPC requests handled by the server in seconds", prometheus.DefBuckets, []string{"service", "method"}).
+		AddCounter(GRPCClientRequestsTotal, "Total number of gRPC requests sent by the client", []string{"service", "method", "code"}).
+		AddHistogram(GRPCClientRequestDuration, "Duration of gRPC requests sent by the client in seconds", prometheus.DefBuckets, []string{"service", "method"})
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RejectedLabelSetsTotal = "metrics_rejected_label_sets_total"
+
+	// OverflowLabelValue replaces label values rejected by a CardinalityGuard.
+	OverflowLabelValue = "__overflow__"
+
+	rejectReasonMaxSeries  = "max_series"
+	rejectReasonNotAllowed = "not_allowed"
+
+	// maxRejectedLabelSets bounds the rejected label sets a guard remembers in order to
+	// count each once. Later rejected label sets are not counted.
+	maxRejectedLabelSets = 10000
+)
+
+// GuardOption configures a CardinalityGuard.
+type GuardOption func(*CardinalityGuard)
+
+// WithMaxSeries limits the metric to max series. The limit includes the overflow series,
+// whose labels are all OverflowLabelValue: once max-1 distinct label sets are recorded,
+// new label sets are recorded in it.
+func WithMaxSeries(max int) GuardOption {
+	return func(g *CardinalityGuard) {
+		g.maxSeries = max
+	}
+}
+
+// WithLabelAllowlist replaces values of label outside values with OverflowLabelValue.
+func WithLabelAllowlist(label string, values ...string) GuardOption {
+	allowed := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		allowed[v] = struct{}{}
+	}
+	return func(g *CardinalityGuard) {
+		g.allowlists[label] = allowed
+	}
+}
+
+// WithLabelNormalizer rewrites values of label before they are checked, e.g. to strip IDs
+// from a path or name an empty route. It runs before the allowlist.
+func WithLabelNormalizer(label string, normalize func(string) string) GuardOption {
+	return func(g *CardinalityGuard) {
+		g.normalizers[label] = normalize
+	}
+}
+
+// CardinalityGuard bounds the series of a metric vector. Label values pass through
+// Values before reaching WithLabelValues; each distinct rejected label set is counted
+// once in metrics_rejected_label_sets_total by metric and reason.
+type CardinalityGuard struct {
+	metric      string
+	labels      []string
+	maxSeries   int
+	allowlists  map[string]map[string]struct{}
+	normalizers map[string]func(string) string
+	rejected    *prometheus.CounterVec
+	series      *Limiter
+
+	mu           sync.Mutex
+	rejectedSets map[string]struct{}
+}
+
+// NewCardinalityGuard creates a guard for the metric with the given label names, counting
+// rejections in the self-metric registered on reg.
+func NewCardinalityGuard(reg prometheus.Registerer, metric string, labels []string, opts ...GuardOption) (*CardinalityGuard, error) {
+	rejected, err := rejectedLabelSets(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &CardinalityGuard{
+		metric:      metric,
+		labels:      labels,
+		allowlists:  make(map[string]map[string]struct{}),
+		normalizers: make(map[string]func(string) string),
+		rejected:    rejected,
+
+		rejectedSets: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.maxSeries > 0 {
+		// One series is kept for the overflow.
+		g.series = NewLimiter(g.maxSeries - 1)
+	}
+	return g, nil
+}
+
+// Values returns the label values to record for values, in the order of the guard's
+// labels. A nil guard returns values unchanged.
+func (g *CardinalityGuard) Values(values ...string) []string {
+	if g == nil {
+		return values
+	}
+
+	guarded := make([]string, len(values))
+	var notAllowed []int
+	for i, value := range values {
+		if i < len(g.labels) {
+			label := g.labels[i]
+			if normalize, ok := g.normalizers[label]; ok {
+				value = normalize(value)
+			}
+			if allowed, ok := g.allowlists[label]; ok {
+				if _, ok := allowed[value]; !ok {
+					notAllowed = append(notAllowed, i)
+				}
+			}
+		}
+		guarded[i] = value
+	}
+
+	if len(notAllowed) > 0 {
+		g.reject(rejectReasonNotAllowed, strings.Join(guarded, "\xff"))
+		for _, i := range notAllowed {
+			guarded[i] = OverflowLabelValue
+		}
+	}
+
+	key := strings.Join(guarded, "\xff")
+	if g.series == nil || g.overflow(guarded) || g.series.Admit(key) {
+		return guarded
+	}
+
+	g.reject(rejectReasonMaxSeries, key)
+	for i := range guarded {
+		guarded[i] = OverflowLabelValue
+	}
+	return guarded
+}
+
+// overflow reports whether values are the labels of the overflow series.
+func (g *CardinalityGuard) overflow(values []string) bool {
+	for _, value := range values {
+		if value != OverflowLabelValue {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+// Labels is Values for labels given by name.
+func (g *CardinalityGuard) Labels(labels prometheus.Labels) prometheus.Labels {
+	if g == nil {
+		return labels
+	}
+
+	values := make([]string, len(g.labels))
+	for i, name := range g.labels {
+		values[i] = labels[name]
+	}
+	guarded := make(prometheus.Labels, len(labels))
+	for name, value := range labels {
+		guarded[name] = value
+	}
+	for i, value := range g.Values(values...) {
+		if _, ok := labels[g.labels[i]]; ok {
+			guarded[g.labels[i]] = value
+		}
+	}
+	return guarded
+}
+
+// reject counts the label set key the first time it is rejected for reason.
+func (g *CardinalityGuard) reject(reason, key string) {
+	key = reason + "\xff" + key
+
+	g.mu.Lock()
+	_, seen := g.rejectedSets[key]
+	if !seen && len(g.rejectedSets) < maxRejectedLabelSets {
+		g.rejectedSets[key] = struct{}{}
+	} else {
+		seen = true
+	}
+	g.mu.Unlock()
+
+	if !seen {
+		g.rejected.WithLabelValues(g.metric, reason).Inc()
+	}
+}
+
+// rejectedLabelSets registers the guard self-metric on reg, or returns the one already registered.
+func rejectedLabelSets(reg prometheus.Registerer) (*prometheus.CounterVec, error) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: RejectedLabelSetsTotal,
+		Help: "Total number of label sets rewritten by a cardinality guard, by metric and reason",
+	}, []string{"metric", "reason"})
+
+	if err := reg.Register(counter); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return counter, nil
+}
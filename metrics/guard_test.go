@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gathered returns the series of the metric family name gathered from reg.
+func gathered(t *testing.T, reg *prometheus.Registry, name string) []*dto.Metric {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()
+		}
+	}
+	return nil
+}
+
+// labelValues returns the values of a series' labels, ordered by label name.
+func labelValues(m *dto.Metric) string {
+	values := make([]string, len(m.GetLabel()))
+	for i, label := range m.GetLabel() {
+		values[i] = label.GetValue()
+	}
+	return strings.Join(values, ",")
+}
+
+// rejectedCount returns metrics_rejected_label_sets_total for metric and reason.
+func rejectedCount(t *testing.T, reg *prometheus.Registry, metric, reason string) float64 {
+	t.Helper()
+	for _, m := range gathered(t, reg, RejectedLabelSetsTotal) {
+		if labelValues(m) == metric+","+reason {
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestCardinalityGuardValues(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []GuardOption
+		values       [][]string
+		want         []string
+		wantRejected map[string]float64
+	}{
+		{
+			name:   "no options",
+			values: [][]string{{"GET", "/a"}, {"POST", "/b"}},
+			want:   []string{"GET,/a", "POST,/b"},
+		},
+		{
+			name:         "max series includes the overflow series",
+			opts:         []GuardOption{WithMaxSeries(2)},
+			values:       [][]string{{"GET", "/a"}, {"GET", "/b"}, {"GET", "/c"}, {"GET", "/a"}, {"GET", "/c"}},
+			want:         []string{"GET,/a", "__overflow__,__overflow__", "__overflow__,__overflow__", "GET,/a", "__overflow__,__overflow__"},
+			wantRejected: map[string]float64{rejectReasonMaxSeries: 2},
+		},
+		{
+			name:         "allowlist",
+			opts:         []GuardOption{WithLabelAllowlist("method", "GET", "POST")},
+			values:       [][]string{{"GET", "/a"}, {"BREW", "/a"}, {"BREW", "/a"}},
+			want:         []string{"GET,/a", "__overflow__,/a", "__overflow__,/a"},
+			wantRejected: map[string]float64{rejectReasonNotAllowed: 1},
+		},
+		{
+			name: "normalizer runs before the allowlist",
+			opts: []GuardOption{
+				WithLabelNormalizer("method", strings.ToUpper),
+				WithLabelAllowlist("method", "GET"),
+			},
+			values: [][]string{{"get", "/a"}},
+			want:   []string{"GET,/a"},
+		},
+		{
+			name: "allowlisted overflow set takes no series",
+			opts: []GuardOption{
+				WithMaxSeries(2),
+				WithLabelAllowlist("method", "GET"),
+				WithLabelAllowlist("path", "/a"),
+			},
+			values:       [][]string{{"BREW", "/x"}, {"GET", "/a"}},
+			want:         []string{"__overflow__,__overflow__", "GET,/a"},
+			wantRejected: map[string]float64{rejectReasonNotAllowed: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			g, err := NewCardinalityGuard(reg, "requests_total", []string{"method", "path"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewCardinalityGuard: %v", err)
+			}
+			for i, values := range tt.values {
+				if got := strings.Join(g.Values(values...), ","); got != tt.want[i] {
+					t.Errorf("Values(%v) = %s, want %s", values, got, tt.want[i])
+				}
+			}
+			for _, reason := range []string{rejectReasonMaxSeries, rejectReasonNotAllowed} {
+				if got := rejectedCount(t, reg, "requests_total", reason); got != tt.wantRejected[reason] {
+					t.Errorf("rejected %s = %v, want %v", reason, got, tt.wantRejected[reason])
+				}
+			}
+		})
+	}
+}
+
+func TestCardinalityGuardLabels(t *testing.T) {
+	g, err := NewCardinalityGuard(prometheus.NewRegistry(), "requests_total", []string{"method", "path"},
+		WithLabelAllowlist("method", "GET"))
+	if err != nil {
+		t.Fatalf("NewCardinalityGuard: %v", err)
+	}
+
+	got := g.Labels(prometheus.Labels{"method": "BREW", "path": "/a"})
+	if got["method"] != OverflowLabelValue || got["path"] != "/a" {
+		t.Errorf("Labels() = %v, want method=%s path=/a", got, OverflowLabelValue)
+	}
+
+	var nilGuard *CardinalityGuard
+	if got := nilGuard.Values("BREW"); got[0] != "BREW" {
+		t.Errorf("nil guard Values() = %v, want unchanged", got)
+	}
+}
+
+func TestGuardedVectorsKeepSeriesWithinLimit(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetricsBuilder(WithRegisterer(reg)).
+		AddCounter("requests_total", "Requests", []string{"path"}, WithGuard(WithMaxSeries(3))).
+		AddHistogram("request_seconds", "Latency", nil, []string{"path"}, WithGuard(WithMaxSeries(3))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		m.Counters["requests_total"].WithLabelValues(path).Inc()
+		m.Histograms["request_seconds"].With(prometheus.Labels{"path": path}).Observe(0.1)
+	}
+
+	for _, name := range []string{"requests_total", "request_seconds"} {
+		series := gathered(t, reg, name)
+		if len(series) != 3 {
+			t.Fatalf("%s has %d series, want 3", name, len(series))
+		}
+		if got := labelValues(series[2]); got != OverflowLabelValue {
+			t.Errorf("%s last series = %s, want %s", name, got, OverflowLabelValue)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import "sync"
+
+// OtherLabelValue replaces label values past a cardinality limit.
+const OtherLabelValue = "other"
+
+// Limiter bounds the cardinality of a label. The first max distinct values seen are
+// admitted; every later value is rejected and reported as OtherLabelValue.
+type Limiter struct {
+	max  int
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+// NewLimiter returns a Limiter admitting up to max distinct values.
+func NewLimiter(max int) *Limiter {
+	return &Limiter{max: max, seen: make(map[string]struct{})}
+}
+
+// Admit reports whether value is already known or there is room to add it.
+func (l *Limiter) Admit(value string) bool {
+	l.mu.RLock()
+	_, ok := l.seen[value]
+	l.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[value]; ok {
+		return true
+	}
+	if len(l.seen) >= l.max {
+		return false
+	}
+	l.seen[value] = struct{}{}
+	return true
+}
+
+// Value returns value if it is admitted and OtherLabelValue otherwise.
+func (l *Limiter) Value(value string) string {
+	if l.Admit(value) {
+		return value
+	}
+	return OtherLabelValue
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const MethodDurationSeconds = "method_duration_seconds"
+
+// AddMethodMetrics registers the histogram used by decorators generated with cmd/tracegen.
+func (b *MetricsBuilder) AddMethodMetrics() *MetricsBuilder {
+	return b.AddHistogram(MethodDurationSeconds, "Duration of traced method calls in seconds", prometheus.DefBuckets, []string{"method", "outcome"})
+}
+
+// ObserveMethodDuration records the time since start for method, labelled by whether err is nil.
+// It is a no-op when m is nil or AddMethodMetrics was not called.
+func ObserveMethodDuration(m *Metrics, method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	histogram, ok := m.Histograms[MethodDurationSeconds]
+	if !ok {
+		return
+	}
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	histogram.WithLabelValues(method, outcome).Observe(time.Since(start).Seconds())
+}
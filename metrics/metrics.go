@@ -1,55 +1,232 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Metrics struct {
-	Counters   map[string]*prometheus.CounterVec
-	Histograms map[string]*prometheus.HistogramVec
-	Gauges     map[string]*prometheus.GaugeVec
+	Counters   map[string]*CounterVec
+	Histograms map[string]*HistogramVec
+	Gauges     map[string]*GaugeVec
+	Summaries  map[string]*SummaryVec
+	Guards     map[string]*CardinalityGuard
+
+	// definitions lists the metrics in the order they were added, for Dashboard.
+	definitions []definition
 }
 
 type MetricsBuilder struct {
-	metrics *Metrics
+	metrics    *Metrics
+	registerer prometheus.Registerer
+	// keys holds the names the metrics are stored under in Metrics and fqNames their
+	// fully qualified names, so neither is added twice.
+	keys    map[string]struct{}
+	fqNames map[string]struct{}
+	errs    []error
+
+	standardCollectors bool
+	namespace          string
+}
+
+// BuilderOption configures a MetricsBuilder.
+type BuilderOption func(*MetricsBuilder)
+
+// WithRegisterer registers the metrics on reg instead of the default registry.
+func WithRegisterer(reg prometheus.Registerer) BuilderOption {
+	return func(b *MetricsBuilder) {
+		b.registerer = reg
+	}
 }
 
-func NewMetricsBuilder() *MetricsBuilder {
-	return &MetricsBuilder{
+func NewMetricsBuilder(opts ...BuilderOption) *MetricsBuilder {
+	b := &MetricsBuilder{
 		metrics: &Metrics{
-			Counters:   make(map[string]*prometheus.CounterVec),
-			Histograms: make(map[string]*prometheus.HistogramVec),
-			Gauges:     make(map[string]*prometheus.GaugeVec),
+			Counters:   make(map[string]*CounterVec),
+			Histograms: make(map[string]*HistogramVec),
+			Gauges:     make(map[string]*GaugeVec),
+			Summaries:  make(map[string]*SummaryVec),
+			Guards:     make(map[string]*CardinalityGuard),
 		},
+		registerer: prometheus.DefaultRegisterer,
+		keys:       make(map[string]struct{}),
+		fqNames:    make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.standardCollectors {
+		b.registerStandardCollectors()
 	}
+	return b
 }
 
-func (b *MetricsBuilder) AddCounter(name, help string, labels []string) *MetricsBuilder {
-	b.metrics.Counters[name] = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: name,
-		Help: help,
-	}, labels)
+func (b *MetricsBuilder) AddCounter(name, help string, labels []string, opts ...MetricOption) *MetricsBuilder {
+	b.addCounter(name, help, labels, opts)
 	return b
 }
 
-func (b *MetricsBuilder) AddHistogram(name, help string, buckets []float64, labels []string) *MetricsBuilder {
-	b.metrics.Histograms[name] = promauto.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    name,
-		Help:    help,
-		Buckets: buckets,
-	}, labels)
+// addCounter adds the counter and returns it, or nil if it failed to register.
+func (b *MetricsBuilder) addCounter(name, help string, labels []string, opts []MetricOption) *CounterVec {
+	cfg := newMetricConfig(name, help, opts)
+	if !b.validate(cfg.opts, labels) {
+		return nil
+	}
+	counter, ok := register(b, cfg.opts, prometheus.NewCounterVec(prometheus.CounterOpts(cfg.opts), labels))
+	if !ok {
+		return nil
+	}
+	vec := &CounterVec{CounterVec: counter, guard: b.addGuard(name, cfg, labels)}
+	b.metrics.Counters[name] = vec
+	b.define(kindCounter, cfg, labels)
+	return vec
+}
+
+func (b *MetricsBuilder) AddHistogram(name, help string, buckets []float64, labels []string, opts ...MetricOption) *MetricsBuilder {
+	b.addHistogram(name, help, buckets, labels, opts)
 	return b
 }
 
-func (b *MetricsBuilder) AddGauge(name, help string, labels []string) *MetricsBuilder {
-	b.metrics.Gauges[name] = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: name,
-		Help: help,
-	}, labels)
+// addHistogram adds the histogram and returns it, or nil if it failed to register.
+func (b *MetricsBuilder) addHistogram(name, help string, buckets []float64, labels []string, opts []MetricOption) *HistogramVec {
+	cfg := newMetricConfig(name, help, opts)
+	cfg.histogram.Buckets = buckets
+	if !b.validate(cfg.opts, labels) {
+		return nil
+	}
+	histogram, ok := register(b, cfg.opts, prometheus.NewHistogramVec(cfg.histogramOpts(), labels))
+	if !ok {
+		return nil
+	}
+	vec := &HistogramVec{HistogramVec: histogram, guard: b.addGuard(name, cfg, labels)}
+	b.metrics.Histograms[name] = vec
+	b.define(kindHistogram, cfg, labels)
+	return vec
+}
+
+func (b *MetricsBuilder) AddGauge(name, help string, labels []string, opts ...MetricOption) *MetricsBuilder {
+	b.addGauge(name, help, labels, opts)
+	return b
+}
+
+// addGauge adds the gauge and returns it, or nil if it failed to register.
+func (b *MetricsBuilder) addGauge(name, help string, labels []string, opts []MetricOption) *GaugeVec {
+	cfg := newMetricConfig(name, help, opts)
+	if !b.validate(cfg.opts, labels) {
+		return nil
+	}
+	gauge, ok := register(b, cfg.opts, prometheus.NewGaugeVec(prometheus.GaugeOpts(cfg.opts), labels))
+	if !ok {
+		return nil
+	}
+	vec := &GaugeVec{GaugeVec: gauge, guard: b.addGuard(name, cfg, labels)}
+	b.metrics.Gauges[name] = vec
+	b.define(kindGauge, cfg, labels)
+	return vec
+}
+
+// AddSummary adds a summary. Without WithObjectives it only exposes the sum and count.
+func (b *MetricsBuilder) AddSummary(name, help string, labels []string, opts ...MetricOption) *MetricsBuilder {
+	b.addSummary(name, help, labels, opts)
 	return b
 }
 
-func (b *MetricsBuilder) Build() *Metrics {
-	return b.metrics
+// addSummary adds the summary and returns it, or nil if it failed to register.
+func (b *MetricsBuilder) addSummary(name, help string, labels []string, opts []MetricOption) *SummaryVec {
+	cfg := newMetricConfig(name, help, opts)
+	if !b.validate(cfg.opts, labels) {
+		return nil
+	}
+	summary, ok := register(b, cfg.opts, prometheus.NewSummaryVec(cfg.summaryOpts(), labels))
+	if !ok {
+		return nil
+	}
+	vec := &SummaryVec{SummaryVec: summary, guard: b.addGuard(name, cfg, labels)}
+	b.metrics.Summaries[name] = vec
+	b.define(kindSummary, cfg, labels)
+	return vec
+}
+
+// addGuard creates the CardinalityGuard of a metric added WithGuard, or returns nil.
+func (b *MetricsBuilder) addGuard(name string, cfg *metricConfig, labels []string) *CardinalityGuard {
+	if !cfg.guarded {
+		return nil
+	}
+	guard, err := NewCardinalityGuard(b.registerer, name, labels, cfg.guard...)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("metrics: guard of %q: %w", name, err))
+		return nil
+	}
+	b.metrics.Guards[name] = guard
+	return guard
+}
+
+func (b *MetricsBuilder) define(kind metricKind, cfg *metricConfig, labels []string) {
+	b.metrics.definitions = append(b.metrics.definitions, definition{
+		kind:   kind,
+		fqName: prometheus.BuildFQName(cfg.opts.Namespace, cfg.opts.Subsystem, cfg.opts.Name),
+		help:   cfg.opts.Help,
+		labels: labels,
+		native: kind == kindHistogram && cfg.nativeOnly(),
+	})
+}
+
+// validate records an error for Build if the names of the metric break the Prometheus naming rules.
+func (b *MetricsBuilder) validate(opts prometheus.Opts, labels []string) bool {
+	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	if err := validateNames(fqName, labels, opts.ConstLabels); err != nil {
+		b.errs = append(b.errs, err)
+		return false
+	}
+	return true
+}
+
+// register registers collector and returns the collector to use: collector itself or,
+// if an identical metric is already registered, the existing one. Errors, including a
+// metric added twice to the builder or an existing metric of another type, are reported
+// by Build and false is returned.
+func register[C prometheus.Collector](b *MetricsBuilder, opts prometheus.Opts, collector C) (C, bool) {
+	var none C
+	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	if _, ok := b.fqNames[fqName]; ok {
+		b.errs = append(b.errs, fmt.Errorf("metrics: %q added more than once", fqName))
+		return none, false
+	}
+	if _, ok := b.keys[opts.Name]; ok {
+		b.errs = append(b.errs, fmt.Errorf("metrics: %q added more than once with different namespaces or subsystems", opts.Name))
+		return none, false
+	}
+	b.fqNames[fqName] = struct{}{}
+	b.keys[opts.Name] = struct{}{}
+	return reuse(b, fqName, collector)
+}
+
+// reuse registers collector and returns it or, if an identical collector is already
+// registered, the existing one. Errors are reported by Build and false is returned.
+func reuse[C prometheus.Collector](b *MetricsBuilder, name string, collector C) (C, bool) {
+	var none C
+	if err := b.registerer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			b.errs = append(b.errs, fmt.Errorf("metrics: registering %q: %w", name, err))
+			return none, false
+		}
+		existing, ok := are.ExistingCollector.(C)
+		if !ok {
+			b.errs = append(b.errs, fmt.Errorf("metrics: %q is already registered as %T, not %T", name, are.ExistingCollector, collector))
+			return none, false
+		}
+		return existing, true
+	}
+	return collector, true
+}
+
+// Build returns the metrics, or the errors of every metric that could not be registered.
+func (b *MetricsBuilder) Build() (*Metrics, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+	return b.metrics, nil
 }
@@ -1,51 +1,316 @@
 package metrics
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
+	apw_tenant "github.com/kyon1313/observability/tenant"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// HTTPLabel is a label the HTTP metrics middleware can derive from a request.
+type HTTPLabel string
+
+const (
+	LabelMethod      HTTPLabel = "method"
+	LabelRoute       HTTPLabel = "route"
+	LabelStatusCode  HTTPLabel = "status_code"
+	LabelStatusClass HTTPLabel = "status_class"
+	LabelHandler     HTTPLabel = "handler"
+	LabelTenant      HTTPLabel = "tenant"
+
+	// labelPath is the route label under the name used by the metrics of NewMetricsMiddlewareDecorator.
+	labelPath HTTPLabel = "path"
+)
+
+// HTTPMetricsConfig configures the metrics registered by NewHTTPMetricsMiddleware.
+type HTTPMetricsConfig struct {
+	Namespace string
+	Subsystem string
+	// Labels of the request metrics. Defaults to method, route and status class.
+	Labels []HTTPLabel
+	// Buckets of the duration histogram. Defaults to prometheus.DefBuckets.
+	Buckets []float64
+	// SizeBuckets of the request and response size histograms. Defaults to powers of 4 from 64 bytes to 1 MiB.
+	SizeBuckets []float64
+	// MaxTenants bounds the tenant label values when Labels contains LabelTenant. Defaults to 100.
+	MaxTenants int
+	// Registerer defaults to prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Guard bounds the series of the metrics, e.g. WithMaxSeries(1000). Requests matching
+	// no route are labelled "unmatched" unless a route normalizer is given.
+	Guard []GuardOption
+}
+
+// UnmatchedRoute is the route label value of requests matching no route.
+const UnmatchedRoute = "unmatched"
+
+// MetricsMiddlewareDecorator records the count, errors, duration and in-flight requests of a gin router.
 type MetricsMiddlewareDecorator struct {
-	metrics *Metrics
+	labels       []HTTPLabel
+	activeLabels []HTTPLabel
+	requests     *CounterVec
+	errors       *CounterVec
+	duration     *HistogramVec
+	requestSize  *HistogramVec
+	responseSize *HistogramVec
+	active       *GaugeVec
+	tenants      *Limiter
+}
+
+// NewHTTPMetricsMiddleware creates and registers the HTTP metrics described by cfg:
+// http_requests_total, http_errors_total, http_request_duration_seconds,
+// http_request_size_bytes, http_response_size_bytes and http_active_requests.
+// In-flight requests are only labelled by method, route and handler, since the status
+// and tenant are not known until the request completes.
+func NewHTTPMetricsMiddleware(cfg HTTPMetricsConfig) (*MetricsMiddlewareDecorator, error) {
+	if cfg.Labels == nil {
+		cfg.Labels = []HTTPLabel{LabelMethod, LabelRoute, LabelStatusClass}
+	}
+	if cfg.Buckets == nil {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+	if cfg.SizeBuckets == nil {
+		cfg.SizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+	}
+	if cfg.MaxTenants == 0 {
+		cfg.MaxTenants = 100
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &MetricsMiddlewareDecorator{labels: cfg.Labels}
+	seen := make(map[HTTPLabel]bool, len(cfg.Labels))
+	for _, label := range cfg.Labels {
+		switch label {
+		case LabelMethod, LabelRoute, LabelHandler:
+			m.activeLabels = append(m.activeLabels, label)
+		case LabelStatusCode, LabelStatusClass:
+		case LabelTenant:
+			m.tenants = NewLimiter(cfg.MaxTenants)
+		default:
+			return nil, fmt.Errorf("unknown HTTP metrics label %q", label)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate HTTP metrics label %q", label)
+		}
+		seen[label] = true
+	}
+
+	labels := labelNames(m.labels)
+	guardOpts := append([]GuardOption{WithLabelNormalizer(string(LabelRoute), normalizeRoute)}, cfg.Guard...)
+	guard, err := NewCardinalityGuard(cfg.Registerer, prometheus.BuildFQName(cfg.Namespace, cfg.Subsystem, "http_requests_total"), labels, guardOpts...)
+	if err != nil {
+		return nil, err
+	}
+	activeGuard, err := NewCardinalityGuard(cfg.Registerer, prometheus.BuildFQName(cfg.Namespace, cfg.Subsystem, "http_active_requests"), labelNames(m.activeLabels), guardOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The vectors share a guard so a request is admitted or rejected in all of them.
+	m.requests = &CounterVec{guard: guard, CounterVec: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests",
+	}, labels)}
+	m.errors = &CounterVec{guard: guard, CounterVec: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_errors_total",
+		Help:      "Total number of HTTP requests answered with a 4xx or 5xx status",
+	}, labels)}
+	m.duration = &HistogramVec{guard: guard, HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests in seconds",
+		Buckets:   cfg.Buckets,
+	}, labels)}
+	m.requestSize = &HistogramVec{guard: guard, HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_request_size_bytes",
+		Help:      "Size of HTTP request bodies in bytes",
+		Buckets:   cfg.SizeBuckets,
+	}, labels)}
+	m.responseSize = &HistogramVec{guard: guard, HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_response_size_bytes",
+		Help:      "Size of HTTP response bodies in bytes",
+		Buckets:   cfg.SizeBuckets,
+	}, labels)}
+	m.active = &GaugeVec{guard: activeGuard, GaugeVec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "http_active_requests",
+		Help:      "Number of HTTP requests in flight",
+	}, labelNames(m.activeLabels))}
+
+	var registered []prometheus.Collector
+	for _, collector := range []prometheus.Collector{m.requests, m.errors, m.duration, m.requestSize, m.responseSize, m.active} {
+		if err := cfg.Registerer.Register(collector); err != nil {
+			for _, c := range registered {
+				cfg.Registerer.Unregister(c)
+			}
+			return nil, err
+		}
+		registered = append(registered, collector)
+	}
+	return m, nil
 }
 
-func NewMetricsMiddlewareDecorator(metrics *Metrics) *MetricsMiddlewareDecorator {
-	return &MetricsMiddlewareDecorator{metrics: metrics}
+// NewMetricsMiddlewareDecorator records requests in the http_requests_total,
+// http_errors_total, http_request_duration_seconds and active_sessions metrics of a
+// MetricsBuilder, labelled by path. It returns an error if any of them is missing.
+func NewMetricsMiddlewareDecorator(metrics *Metrics) (*MetricsMiddlewareDecorator, error) {
+	m := &MetricsMiddlewareDecorator{
+		labels:       []HTTPLabel{labelPath},
+		activeLabels: []HTTPLabel{labelPath},
+		requests:     metrics.Counters["http_requests_total"],
+		errors:       metrics.Counters["http_errors_total"],
+		duration:     metrics.Histograms["http_request_duration_seconds"],
+		active:       metrics.Gauges["active_sessions"],
+	}
+
+	switch {
+	case m.requests == nil:
+		return nil, fmt.Errorf("metrics: counter %q is not registered", "http_requests_total")
+	case m.errors == nil:
+		return nil, fmt.Errorf("metrics: counter %q is not registered", "http_errors_total")
+	case m.duration == nil:
+		return nil, fmt.Errorf("metrics: histogram %q is not registered", "http_request_duration_seconds")
+	case m.active == nil:
+		return nil, fmt.Errorf("metrics: gauge %q is not registered", "active_sessions")
+	}
+	return m, nil
+}
+
+// WithTenantLabel adds the request tenant as a label after path on the request
+// counters and duration histogram, so they must be declared with labels {path, tenant}.
+// At most maxTenants distinct tenants are labelled; the rest are counted as "other".
+func (m *MetricsMiddlewareDecorator) WithTenantLabel(maxTenants int) *MetricsMiddlewareDecorator {
+	m.labels = append(m.labels, LabelTenant)
+	m.tenants = NewLimiter(maxTenants)
+	return m
 }
 
 func (m *MetricsMiddlewareDecorator) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.FullPath()
 
-		rw := &responseWriter{ResponseWriter: c.Writer}
-		c.Writer = rw
+		// Handlers may wrap c.Writer; gin's own writer counts every byte that reaches it,
+		// including those written with WriteString.
+		writer := c.Writer
 
-		// Simulate active sessions
-		m.metrics.Gauges["active_sessions"].WithLabelValues(path).Inc()
-		defer m.metrics.Gauges["active_sessions"].WithLabelValues(path).Dec()
+		// Chunked requests have no Content-Length; count what the handlers read instead.
+		var body *countingReader
+		if c.Request.ContentLength < 0 && c.Request.Body != nil {
+			body = &countingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		requestSize := c.Request.ContentLength
+
+		if active, err := m.active.GetMetricWithLabelValues(m.labelValues(c, m.activeLabels)...); err == nil {
+			active.Inc()
+			defer active.Dec()
+		}
 
 		c.Next()
 
 		duration := time.Since(start).Seconds()
 
-		m.metrics.Counters["http_requests_total"].WithLabelValues(path).Inc()
-		m.metrics.Histograms["http_request_duration_seconds"].WithLabelValues(path).Observe(duration)
+		// The request context now carries the span started by the tracing middleware,
+		// which becomes the exemplar when it is sampled.
+		ctx := c.Request.Context()
+		labels := m.labelValues(c, m.labels)
 
+		// Label mismatches with metrics declared by the caller are dropped rather than panicking.
+		if counter, err := m.requests.GetMetricWithLabelValues(labels...); err == nil {
+			IncWithTrace(ctx, counter)
+		}
+		if observer, err := m.duration.GetMetricWithLabelValues(labels...); err == nil {
+			ObserveWithTrace(ctx, observer, duration)
+		}
 		if c.Writer.Status() >= 400 {
-			m.metrics.Counters["http_errors_total"].WithLabelValues(path).Inc()
+			if counter, err := m.errors.GetMetricWithLabelValues(labels...); err == nil {
+				IncWithTrace(ctx, counter)
+			}
+		}
+
+		// Size histograms are only registered by NewHTTPMetricsMiddleware.
+		if body != nil {
+			requestSize = body.n
 		}
+		if m.requestSize != nil {
+			if observer, err := m.requestSize.GetMetricWithLabelValues(labels...); err == nil {
+				ObserveWithTrace(ctx, observer, float64(requestSize))
+			}
+		}
+		if m.responseSize != nil {
+			if observer, err := m.responseSize.GetMetricWithLabelValues(labels...); err == nil {
+				ObserveWithTrace(ctx, observer, float64(max(writer.Size(), 0)))
+			}
+		}
+	}
+}
+
+func (m *MetricsMiddlewareDecorator) labelValues(c *gin.Context, labels []HTTPLabel) []string {
+	values := make([]string, len(labels))
+	for i, label := range labels {
+		switch label {
+		case LabelMethod:
+			values[i] = c.Request.Method
+		case LabelRoute, labelPath:
+			values[i] = c.FullPath()
+		case LabelStatusCode:
+			values[i] = strconv.Itoa(c.Writer.Status())
+		case LabelStatusClass:
+			values[i] = strconv.Itoa(c.Writer.Status()/100) + "xx"
+		case LabelHandler:
+			// Unmatched requests have no handler; gin would report the middleware itself.
+			if c.FullPath() != "" {
+				values[i] = c.HandlerName()
+			}
+		case LabelTenant:
+			values[i] = apw_tenant.UnknownLabel
+			if tenantID := apw_tenant.FromContext(c.Request.Context()); tenantID != "" {
+				values[i] = m.tenants.Value(tenantID)
+			}
+		}
+	}
+	return values
+}
+
+func normalizeRoute(route string) string {
+	if route == "" {
+		return UnmatchedRoute
+	}
+	return route
+}
+
+func labelNames(labels []HTTPLabel) []string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = string(label)
 	}
+	return names
 }
 
-type responseWriter struct {
-	gin.ResponseWriter
-	size int
+type countingReader struct {
+	io.ReadCloser
+	n int64
 }
 
-func (w *responseWriter) Write(b []byte) (int, error) {
-	size, err := w.ResponseWriter.Write(b)
-	w.size += size
-	return size, err
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
 }
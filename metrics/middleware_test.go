@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	apw_tenant "github.com/kyon1313/observability/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newMetricsRouter returns a router recording cfg's metrics on a new registry, with a
+// POST /users/:id route answering 200 "ok" and a GET /fail route answering 500. The
+// X-Tenant request header is stored as the tenant.
+func newMetricsRouter(t *testing.T, cfg HTTPMetricsConfig) (*gin.Engine, *prometheus.Registry) {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	cfg.Registerer = reg
+	m, err := NewHTTPMetricsMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("NewHTTPMetricsMiddleware: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if tenantID := c.GetHeader("X-Tenant"); tenantID != "" {
+			ctx, _ := apw_tenant.NewContext(c.Request.Context(), tenantID)
+			c.Request = c.Request.WithContext(ctx)
+		}
+	}, m.Middleware())
+	router.POST("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	return router, reg
+}
+
+func serve(router http.Handler, method, target, body, tenantID string) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if tenantID != "" {
+		req.Header.Set("X-Tenant", tenantID)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// series returns the label values of each series of name with its value, or its sample
+// sum for histograms, e.g. "POST,/users/:id,2xx=1".
+func series(t *testing.T, reg *prometheus.Registry, name string) string {
+	t.Helper()
+	var out []string
+	for _, m := range gathered(t, reg, name) {
+		value := m.GetCounter().GetValue() + m.GetGauge().GetValue() + m.GetHistogram().GetSampleSum()
+		out = append(out, labelValues(m)+"="+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  HTTPMetricsConfig
+		// requests are "METHOD target tenant".
+		requests []string
+		want     map[string]string
+	}{
+		{
+			name:     "default labels",
+			requests: []string{"POST /users/1", "POST /users/2", "GET /fail", "GET /missing"},
+			want: map[string]string{
+				"http_requests_total":  "GET,/fail,5xx=1 GET,unmatched,4xx=1 POST,/users/:id,2xx=2",
+				"http_errors_total":    "GET,/fail,5xx=1 GET,unmatched,4xx=1",
+				"http_active_requests": "GET,/fail=0 GET,unmatched=0 POST,/users/:id=0",
+			},
+		},
+		{
+			name:     "status code and handler",
+			cfg:      HTTPMetricsConfig{Labels: []HTTPLabel{LabelStatusCode, LabelHandler}},
+			requests: []string{"GET /missing"},
+			want: map[string]string{
+				"http_requests_total":  ",404=1",
+				"http_active_requests": "=0",
+			},
+		},
+		{
+			name:     "tenants",
+			cfg:      HTTPMetricsConfig{Labels: []HTTPLabel{LabelTenant}, MaxTenants: 1},
+			requests: []string{"POST /users/1 acme", "POST /users/1 globex", "POST /users/1"},
+			want: map[string]string{
+				"http_requests_total": "acme=1 other=1 unknown=1",
+			},
+		},
+		{
+			name:     "guard",
+			cfg:      HTTPMetricsConfig{Labels: []HTTPLabel{LabelRoute}, Guard: []GuardOption{WithMaxSeries(2)}},
+			requests: []string{"POST /users/1", "GET /fail", "GET /missing"},
+			want: map[string]string{
+				"http_requests_total": "/users/:id=1 __overflow__=2",
+			},
+		},
+		{
+			name:     "namespace",
+			cfg:      HTTPMetricsConfig{Namespace: "shop", Labels: []HTTPLabel{LabelMethod}},
+			requests: []string{"GET /fail"},
+			want: map[string]string{
+				"shop_http_requests_total": "GET=1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, reg := newMetricsRouter(t, tt.cfg)
+			for _, request := range tt.requests {
+				fields := append(strings.Fields(request), "")
+				serve(router, fields[0], fields[1], "", fields[2])
+			}
+			for name, want := range tt.want {
+				if got := series(t, reg, name); got != want {
+					t.Errorf("%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPMetricsMiddlewareSizes(t *testing.T) {
+	router, reg := newMetricsRouter(t, HTTPMetricsConfig{Labels: []HTTPLabel{LabelRoute}})
+	serve(router, http.MethodPost, "/users/1", "hello", "")
+
+	// A chunked request has no Content-Length; the bytes read by the handler are counted.
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("abc"))
+	req.ContentLength = -1
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got, want := series(t, reg, "http_request_size_bytes"), "/users/:id=5"; got != want {
+		t.Errorf("http_request_size_bytes = %s, want %s", got, want)
+	}
+	if got, want := series(t, reg, "http_response_size_bytes"), "/users/:id=4"; got != want {
+		t.Errorf("http_response_size_bytes = %s, want %s", got, want)
+	}
+}
+
+func TestNewHTTPMetricsMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []HTTPLabel
+		want   string
+	}{
+		{"unknown label", []HTTPLabel{"user_id"}, `unknown HTTP metrics label "user_id"`},
+		{"duplicate label", []HTTPLabel{LabelMethod, LabelMethod}, `duplicate HTTP metrics label "method"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPMetricsMiddleware(HTTPMetricsConfig{Labels: tt.labels, Registerer: prometheus.NewRegistry()})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+
+	// A conflicting collector leaves nothing registered, so the middleware can be retried.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "http_active_requests", Help: "Conflicting"}))
+	if _, err := NewHTTPMetricsMiddleware(HTTPMetricsConfig{Registerer: reg}); err == nil {
+		t.Fatal("NewHTTPMetricsMiddleware succeeded despite a conflicting collector")
+	}
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total", Help: "Total number of HTTP requests"},
+		[]string{"method", "route", "status_class"})
+	if err := reg.Register(requests); err != nil {
+		t.Errorf("http_requests_total left registered after the failure: %v", err)
+	}
+}
+
+func TestNewMetricsMiddlewareDecoratorRequiresMetrics(t *testing.T) {
+	m, err := NewMetricsBuilder(WithRegisterer(prometheus.NewRegistry())).
+		AddCounter("http_requests_total", "Requests", []string{"path"}).
+		AddCounter("http_errors_total", "Errors", []string{"path"}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, err := NewMetricsMiddlewareDecorator(m); err == nil || !strings.Contains(err.Error(), "http_request_duration_seconds") {
+		t.Errorf("error = %v, want the missing histogram", err)
+	}
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// MetricOption sets a prometheus option field of a metric added to a MetricsBuilder.
+// Options that do not apply to the metric type, such as objectives on a counter, are ignored.
+type MetricOption func(*metricConfig)
+
+type metricConfig struct {
+	opts prometheus.Opts
+	// histogram and summary hold the type-specific fields only, including the buckets.
+	histogram prometheus.HistogramOpts
+	summary   prometheus.SummaryOpts
+
+	guarded bool
+	guard   []GuardOption
+}
+
+func newMetricConfig(name, help string, opts []MetricOption) *metricConfig {
+	cfg := &metricConfig{opts: prometheus.Opts{Name: name, Help: help}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (c *metricConfig) histogramOpts() prometheus.HistogramOpts {
+	opts := c.histogram
+	opts.Namespace = c.opts.Namespace
+	opts.Subsystem = c.opts.Subsystem
+	opts.Name = c.opts.Name
+	opts.Help = c.opts.Help
+	opts.ConstLabels = c.opts.ConstLabels
+	return opts
+}
+
+// nativeOnly reports whether the histogram only exposes native buckets, which client_golang
+// does when it has a native bucket factor and no classic buckets.
+func (c *metricConfig) nativeOnly() bool {
+	return c.histogram.NativeHistogramBucketFactor > 1 && len(c.histogram.Buckets) == 0
+}
+
+func (c *metricConfig) summaryOpts() prometheus.SummaryOpts {
+	opts := c.summary
+	opts.Namespace = c.opts.Namespace
+	opts.Subsystem = c.opts.Subsystem
+	opts.Name = c.opts.Name
+	opts.Help = c.opts.Help
+	opts.ConstLabels = c.opts.ConstLabels
+	return opts
+}
+
+// WithNamespace prefixes the metric name with namespace.
+func WithNamespace(namespace string) MetricOption {
+	return func(c *metricConfig) {
+		c.opts.Namespace = namespace
+	}
+}
+
+// WithSubsystem prefixes the metric name with subsystem, after the namespace.
+func WithSubsystem(subsystem string) MetricOption {
+	return func(c *metricConfig) {
+		c.opts.Subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets labels with fixed values on every series of the metric.
+func WithConstLabels(labels prometheus.Labels) MetricOption {
+	return func(c *metricConfig) {
+		c.opts.ConstLabels = labels
+	}
+}
+
+// WithNativeHistogram exposes a histogram as a native histogram whose bucket widths grow
+// by at most bucketFactor, e.g. 1.1. Classic buckets are still exposed if any are set.
+func WithNativeHistogram(bucketFactor float64) MetricOption {
+	return func(c *metricConfig) {
+		c.histogram.NativeHistogramBucketFactor = bucketFactor
+	}
+}
+
+// WithNativeHistogramZeroThreshold sets the width of the native histogram zero bucket.
+func WithNativeHistogramZeroThreshold(threshold float64) MetricOption {
+	return func(c *metricConfig) {
+		c.histogram.NativeHistogramZeroThreshold = threshold
+	}
+}
+
+// WithNativeHistogramMaxBuckets limits the native histogram to maxBuckets buckets. Once
+// exceeded the resolution is reduced, or the histogram is reset if minResetDuration has passed.
+func WithNativeHistogramMaxBuckets(maxBuckets uint32, minResetDuration time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		c.histogram.NativeHistogramMaxBucketNumber = maxBuckets
+		c.histogram.NativeHistogramMinResetDuration = minResetDuration
+	}
+}
+
+// WithNativeHistogramMaxZeroThreshold sets how far the zero bucket may widen to stay within the bucket limit.
+func WithNativeHistogramMaxZeroThreshold(threshold float64) MetricOption {
+	return func(c *metricConfig) {
+		c.histogram.NativeHistogramMaxZeroThreshold = threshold
+	}
+}
+
+// WithNativeHistogramExemplars keeps up to maxExemplars exemplars for at least ttl.
+func WithNativeHistogramExemplars(maxExemplars int, ttl time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		c.histogram.NativeHistogramMaxExemplars = maxExemplars
+		c.histogram.NativeHistogramExemplarTTL = ttl
+	}
+}
+
+// WithObjectives sets the quantiles of a summary and their absolute errors, e.g. {0.5: 0.05, 0.99: 0.001}.
+func WithObjectives(objectives map[float64]float64) MetricOption {
+	return func(c *metricConfig) {
+		c.summary.Objectives = objectives
+	}
+}
+
+// WithMaxAge sets how long observations are kept for the summary quantiles.
+func WithMaxAge(maxAge time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		c.summary.MaxAge = maxAge
+	}
+}
+
+// WithAgeBuckets sets the number of buckets used to age out summary observations.
+func WithAgeBuckets(ageBuckets uint32) MetricOption {
+	return func(c *metricConfig) {
+		c.summary.AgeBuckets = ageBuckets
+	}
+}
+
+// WithBufCap sets the buffer size of the summary quantile stream.
+func WithBufCap(bufCap uint32) MetricOption {
+	return func(c *metricConfig) {
+		c.summary.BufCap = bufCap
+	}
+}
+
+// WithGuard puts a CardinalityGuard in front of the metric. It applies to every label
+// value recorded through the vector in Metrics, including those of typed handles.
+func WithGuard(opts ...GuardOption) MetricOption {
+	return func(c *metricConfig) {
+		c.guarded = true
+		c.guard = append(c.guard, opts...)
+	}
+}
+
+// validateNames checks the fully-qualified metric name and all label names against the
+// Prometheus naming rules. Label names starting with "__" are reserved.
+func validateNames(fqName string, labels []string, constLabels prometheus.Labels) error {
+	if !metricNameRE.MatchString(fqName) {
+		return fmt.Errorf("metrics: invalid metric name %q", fqName)
+	}
+
+	seen := make(map[string]struct{}, len(labels)+len(constLabels))
+	check := func(label string) error {
+		if !labelNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("metrics: invalid label name %q on %q", label, fqName)
+		}
+		if _, ok := seen[label]; ok {
+			return fmt.Errorf("metrics: duplicate label name %q on %q", label, fqName)
+		}
+		seen[label] = struct{}{}
+		return nil
+	}
+	for _, label := range labels {
+		if err := check(label); err != nil {
+			return err
+		}
+	}
+	for label := range constLabels {
+		if err := check(label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	defaultPushInterval   = 15 * time.Second
+	defaultPushAttempts   = 3
+	defaultPushBackoff    = 500 * time.Millisecond
+	defaultPushMaxBackoff = 10 * time.Second
+)
+
+// Pusher sends the metrics of a Gatherer to a Pushgateway in the text exposition format,
+// for batch jobs and CLIs that exit before Prometheus can scrape them. Each push replaces
+// the metrics of the job and grouping key on the Pushgateway.
+type Pusher struct {
+	pusher      *push.Pusher
+	client      push.HTTPDoer
+	interval    time.Duration
+	attempts    int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	handleError func(error)
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// PusherOption configures a Pusher.
+type PusherOption func(*Pusher)
+
+// WithPushInterval sets the interval between pushes after Start, which must be positive.
+// Defaults to 15 seconds.
+func WithPushInterval(interval time.Duration) PusherOption {
+	return func(p *Pusher) {
+		p.interval = interval
+	}
+}
+
+// WithGrouping adds a label to the grouping key, e.g. the instance, so that pushes of
+// several jobs with the same name do not replace each other.
+func WithGrouping(name, value string) PusherOption {
+	return func(p *Pusher) {
+		p.pusher.Grouping(name, value)
+	}
+}
+
+// WithBasicAuth authenticates pushes with HTTP basic auth.
+func WithBasicAuth(username, password string) PusherOption {
+	return func(p *Pusher) {
+		p.pusher.BasicAuth(username, password)
+	}
+}
+
+// WithPushClient sends pushes with client instead of http.DefaultClient.
+func WithPushClient(client push.HTTPDoer) PusherOption {
+	return func(p *Pusher) {
+		p.client = client
+	}
+}
+
+// WithPushRetry makes up to attempts pushes, waiting backoff after the first failure and
+// doubling the wait after each one up to maxBackoff. Only transport errors and 5xx
+// responses are retried. Defaults to 3 attempts from 500ms to 10s.
+func WithPushRetry(attempts int, backoff, maxBackoff time.Duration) PusherOption {
+	return func(p *Pusher) {
+		p.attempts = attempts
+		p.backoff = backoff
+		p.maxBackoff = maxBackoff
+	}
+}
+
+// WithPushErrorHandler handles the errors of the pushes made after Start. Defaults to the
+// OpenTelemetry global error handler.
+func WithPushErrorHandler(handle func(error)) PusherOption {
+	return func(p *Pusher) {
+		p.handleError = handle
+	}
+}
+
+// NewPusher returns a Pusher of the metrics gathered by g, or the default registry if g is
+// nil, to the Pushgateway at url under job. It returns an error if the push interval is
+// not positive.
+func NewPusher(url, job string, g prometheus.Gatherer, opts ...PusherOption) (*Pusher, error) {
+	if g == nil {
+		g = prometheus.DefaultGatherer
+	}
+	p := &Pusher{
+		pusher:      push.New(url, job).Gatherer(g).Format(expfmt.NewFormat(expfmt.TypeTextPlain)),
+		client:      http.DefaultClient,
+		interval:    defaultPushInterval,
+		attempts:    defaultPushAttempts,
+		backoff:     defaultPushBackoff,
+		maxBackoff:  defaultPushMaxBackoff,
+		handleError: otel.Handle,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.interval <= 0 {
+		return nil, fmt.Errorf("metrics: push interval must be positive, got %v", p.interval)
+	}
+	p.pusher.Client(pushDoer{client: p.client})
+	return p, nil
+}
+
+// pushOutcomeKey is the context key of the *pushOutcome an attempt records its response in.
+type pushOutcomeKey struct{}
+
+// pushOutcome is how the request of a push attempt ended, since push.Pusher only
+// reports it in the text of its error.
+type pushOutcome struct {
+	statusCode   int
+	transportErr error
+}
+
+// retryable reports whether the attempt failed in a way a later attempt may not: a
+// transport error or a 5xx response. Failures to gather or encode the metrics and 4xx
+// responses are not retried.
+func (o *pushOutcome) retryable() bool {
+	return o.transportErr != nil || o.statusCode >= http.StatusInternalServerError
+}
+
+// pushDoer sends the requests of push.Pusher with client, recording their outcome.
+type pushDoer struct {
+	client push.HTTPDoer
+}
+
+func (d pushDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if outcome, ok := req.Context().Value(pushOutcomeKey{}).(*pushOutcome); ok {
+		if err != nil {
+			outcome.transportErr = err
+		} else {
+			outcome.statusCode = resp.StatusCode
+		}
+	}
+	return resp, err
+}
+
+// Push pushes the metrics, retrying transport errors and 5xx responses with backoff until
+// an attempt succeeds, the attempts are exhausted or ctx is done. Other errors, such as a
+// 4xx response, are returned at once.
+func (p *Pusher) Push(ctx context.Context) error {
+	backoff := p.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		outcome := &pushOutcome{}
+		if err = p.pusher.PushContext(context.WithValue(ctx, pushOutcomeKey{}, outcome)); err == nil {
+			return nil
+		}
+		if !outcome.retryable() {
+			return fmt.Errorf("metrics: push: %w", err)
+		}
+		if attempt >= p.attempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("metrics: push: %w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, p.maxBackoff)
+	}
+	return fmt.Errorf("metrics: push failed after %d attempts: %w", p.attempts, err)
+}
+
+// Start pushes the metrics on the interval until Shutdown is called or ctx is done. ctx
+// also bounds each push. Calling Start on a started Pusher has no effect, even once ctx
+// is done; Shutdown still pushes the metrics a last time.
+func (p *Pusher) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Push(ctx); err != nil {
+					p.handleError(err)
+				}
+			}
+		}
+	}(p.stop, p.done)
+}
+
+// Shutdown stops the pushes started by Start and pushes the metrics a last time, so that
+// the final values of a job reach the Pushgateway before it exits.
+func (p *Pusher) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return p.Push(ctx)
+}
@@ -0,0 +1,325 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushRequest is a push received by a fakePushgateway.
+type pushRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	body     string
+	at       time.Time
+}
+
+// fakePushgateway records pushes and answers them with the status returned by status,
+// given the number of the push starting at 1, or 200 if status is nil.
+type fakePushgateway struct {
+	*httptest.Server
+	status func(n int) int
+
+	mu       sync.Mutex
+	requests []pushRequest
+	pushed   chan struct{}
+}
+
+func newFakePushgateway(t *testing.T, status func(n int) int) *fakePushgateway {
+	t.Helper()
+	gw := &fakePushgateway{status: status, pushed: make(chan struct{}, 100)}
+	gw.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		username, password, _ := r.BasicAuth()
+
+		gw.mu.Lock()
+		gw.requests = append(gw.requests, pushRequest{
+			method:   r.Method,
+			path:     r.URL.Path,
+			username: username,
+			password: password,
+			body:     string(body),
+			at:       time.Now(),
+		})
+		n := len(gw.requests)
+		gw.mu.Unlock()
+
+		code := http.StatusOK
+		if gw.status != nil {
+			code = gw.status(n)
+		}
+		w.WriteHeader(code)
+		gw.pushed <- struct{}{}
+	}))
+	t.Cleanup(gw.Close)
+	return gw
+}
+
+func (gw *fakePushgateway) pushes() []pushRequest {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return append([]pushRequest(nil), gw.requests...)
+}
+
+// wait blocks until n more pushes are received.
+func (gw *fakePushgateway) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-gw.pushed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d pushes, want %d more", len(gw.pushes()), n-i)
+		}
+	}
+}
+
+func newPushRegistry(t *testing.T) (*prometheus.Registry, prometheus.Counter) {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	processed := prometheus.NewCounter(prometheus.CounterOpts{Name: "jobs_processed_total", Help: "Jobs processed"})
+	reg.MustRegister(processed)
+	return reg, processed
+}
+
+func TestPusherStartPushesOnInterval(t *testing.T) {
+	gw := newFakePushgateway(t, nil)
+	reg, processed := newPushRegistry(t)
+	processed.Add(3)
+
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	p.Start(context.Background())
+	p.Start(context.Background())
+	gw.wait(t, 2)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for _, push := range gw.pushes() {
+		if push.method != http.MethodPut || push.path != "/metrics/job/batch" {
+			t.Errorf("push = %s %s, want PUT /metrics/job/batch", push.method, push.path)
+		}
+		if !strings.Contains(push.body, "jobs_processed_total 3") {
+			t.Errorf("push body = %q, want jobs_processed_total 3", push.body)
+		}
+	}
+}
+
+func TestPusherShutdownPushesFinalValues(t *testing.T) {
+	gw := newFakePushgateway(t, nil)
+	reg, processed := newPushRegistry(t)
+
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	p.Start(context.Background())
+	processed.Add(7)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	pushes := gw.pushes()
+	if len(pushes) != 1 {
+		t.Fatalf("received %d pushes, want 1", len(pushes))
+	}
+	if !strings.Contains(pushes[0].body, "jobs_processed_total 7") {
+		t.Errorf("push body = %q, want jobs_processed_total 7", pushes[0].body)
+	}
+
+	// A stopped Pusher still pushes on Shutdown.
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+	if n := len(gw.pushes()); n != 2 {
+		t.Errorf("received %d pushes, want 2", n)
+	}
+}
+
+func TestPusherGroupingAndBasicAuth(t *testing.T) {
+	gw := newFakePushgateway(t, nil)
+	reg, _ := newPushRegistry(t)
+
+	p, err := NewPusher(gw.URL, "batch", reg,
+		WithGrouping("instance", "worker-1"),
+		WithBasicAuth("pusher", "s3cret"))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	push := gw.pushes()[0]
+	if push.path != "/metrics/job/batch/instance/worker-1" {
+		t.Errorf("path = %q, want /metrics/job/batch/instance/worker-1", push.path)
+	}
+	if push.username != "pusher" || push.password != "s3cret" {
+		t.Errorf("basic auth = %q:%q, want pusher:s3cret", push.username, push.password)
+	}
+}
+
+func TestPusherRetriesWithBackoff(t *testing.T) {
+	gw := newFakePushgateway(t, func(n int) int {
+		if n < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	reg, _ := newPushRegistry(t)
+
+	const backoff = 20 * time.Millisecond
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushRetry(3, backoff, 30*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	pushes := gw.pushes()
+	if len(pushes) != 3 {
+		t.Fatalf("received %d pushes, want 3", len(pushes))
+	}
+	// The wait doubles after each failure, capped at the maximum backoff.
+	for i, want := range []time.Duration{backoff, 30 * time.Millisecond} {
+		if got := pushes[i+1].at.Sub(pushes[i].at); got < want {
+			t.Errorf("wait before attempt %d = %v, want at least %v", i+2, got, want)
+		}
+	}
+}
+
+func TestPusherGivesUpAfterAttempts(t *testing.T) {
+	gw := newFakePushgateway(t, func(int) int { return http.StatusInternalServerError })
+	reg, _ := newPushRegistry(t)
+
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushRetry(2, time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	if err := p.Push(context.Background()); err == nil {
+		t.Fatal("Push succeeded against a failing Pushgateway")
+	}
+	if n := len(gw.pushes()); n != 2 {
+		t.Errorf("received %d pushes, want 2", n)
+	}
+}
+
+func TestPusherStopsRetryingWhenContextIsDone(t *testing.T) {
+	gw := newFakePushgateway(t, func(int) int { return http.StatusInternalServerError })
+	reg, _ := newPushRegistry(t)
+
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushRetry(5, time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-gw.pushed
+		cancel()
+	}()
+	if err := p.Push(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Push error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewPusherRejectsNonPositiveInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := NewPusher("http://localhost:9091", "batch", nil, WithPushInterval(interval)); err == nil {
+			t.Errorf("NewPusher with interval %v succeeded", interval)
+		}
+	}
+}
+
+func TestPusherDoesNotRetryClientErrors(t *testing.T) {
+	gw := newFakePushgateway(t, func(int) int { return http.StatusBadRequest })
+	reg, _ := newPushRegistry(t)
+
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushRetry(3, time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	if err := p.Push(context.Background()); err == nil {
+		t.Fatal("Push succeeded against a Pushgateway rejecting the request")
+	}
+	if n := len(gw.pushes()); n != 1 {
+		t.Errorf("received %d pushes, want 1", n)
+	}
+}
+
+// flakyClient fails the first failures requests without sending them.
+type flakyClient struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (c *flakyClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.calls++
+	fail := c.calls <= c.failures
+	c.mu.Unlock()
+	if fail {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestPusherRetriesTransportErrors(t *testing.T) {
+	gw := newFakePushgateway(t, nil)
+	reg, _ := newPushRegistry(t)
+	client := &flakyClient{failures: 2}
+
+	p, err := NewPusher(gw.URL, "batch", reg,
+		WithPushClient(client),
+		WithPushRetry(3, time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if client.calls != 3 || len(gw.pushes()) != 1 {
+		t.Errorf("made %d attempts with %d reaching the Pushgateway, want 3 and 1", client.calls, len(gw.pushes()))
+	}
+}
+
+func TestPusherStartStopsWhenContextIsDone(t *testing.T) {
+	gw := newFakePushgateway(t, nil)
+	reg, _ := newPushRegistry(t)
+
+	// A push in flight when ctx is done fails.
+	p, err := NewPusher(gw.URL, "batch", reg, WithPushInterval(10*time.Millisecond), WithPushErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Start(ctx)
+	gw.wait(t, 1)
+	cancel()
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pushes did not stop when the context was done")
+	}
+	pushed := len(gw.pushes())
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if n := len(gw.pushes()); n != pushed+1 {
+		t.Errorf("received %d pushes, want %d", n, pushed+1)
+	}
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	SpansTotal          = "spans_total"
+	SpanDurationSeconds = "span_duration_seconds"
+
+	defaultMaxSpanNames = 1000
+)
+
+// SpanMetricsOption configures a SpanMetricsProcessor.
+type SpanMetricsOption func(*spanMetricsConfig)
+
+type spanDimension struct {
+	key string
+	max int
+}
+
+type spanMetricsConfig struct {
+	buckets      []float64
+	maxSpanNames int
+	dimensions   []spanDimension
+}
+
+// WithSpanMetricsBuckets sets the duration histogram buckets. Defaults to prometheus.DefBuckets.
+func WithSpanMetricsBuckets(buckets []float64) SpanMetricsOption {
+	return func(c *spanMetricsConfig) {
+		c.buckets = buckets
+	}
+}
+
+// WithMaxSpanNames bounds the number of distinct span_name label values. Defaults to 1000.
+func WithMaxSpanNames(max int) SpanMetricsOption {
+	return func(c *spanMetricsConfig) {
+		c.maxSpanNames = max
+	}
+}
+
+// WithSpanDimension adds the span attribute key as a label, with at most max distinct values.
+// The label name is the key with dots replaced by underscores.
+func WithSpanDimension(key string, max int) SpanMetricsOption {
+	return func(c *spanMetricsConfig) {
+		c.dimensions = append(c.dimensions, spanDimension{key: key, max: max})
+	}
+}
+
+// SpanMetricsProcessor is a span processor recording rate, errors and duration of every
+// ended span, labelled by span name, kind and status code. Errors are the spans with
+// status_code="Error".
+type SpanMetricsProcessor struct {
+	total      *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	names      *Limiter
+	dimensions []spanDimension
+	limiters   []*Limiter
+}
+
+// NewSpanMetricsProcessor creates the span metrics and registers them on reg, or on the
+// default registry if reg is nil.
+func NewSpanMetricsProcessor(reg prometheus.Registerer, opts ...SpanMetricsOption) (*SpanMetricsProcessor, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	cfg := spanMetricsConfig{
+		buckets:      prometheus.DefBuckets,
+		maxSpanNames: defaultMaxSpanNames,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	labels := []string{"span_name", "span_kind", "status_code"}
+	limiters := make([]*Limiter, len(cfg.dimensions))
+	for i, dim := range cfg.dimensions {
+		labels = append(labels, sanitizeLabelName(dim.key))
+		limiters[i] = NewLimiter(dim.max)
+	}
+
+	p := &SpanMetricsProcessor{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: SpansTotal,
+			Help: "Total number of ended spans",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    SpanDurationSeconds,
+			Help:    "Duration of spans in seconds",
+			Buckets: cfg.buckets,
+		}, labels),
+		names:      NewLimiter(cfg.maxSpanNames),
+		dimensions: cfg.dimensions,
+		limiters:   limiters,
+	}
+
+	if err := reg.Register(p.total); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(p.duration); err != nil {
+		reg.Unregister(p.total)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *SpanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *SpanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	values := []string{p.names.Value(s.Name()), s.SpanKind().String(), s.Status().Code.String()}
+	if len(p.dimensions) > 0 {
+		attrs := make(map[string]string, len(p.dimensions))
+		for _, kv := range s.Attributes() {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		for i, dim := range p.dimensions {
+			values = append(values, p.limiters[i].Value(attrs[dim.key]))
+		}
+	}
+
+	p.total.WithLabelValues(values...).Inc()
+	p.duration.WithLabelValues(values...).Observe(s.EndTime().Sub(s.StartTime()).Seconds())
+}
+
+func (p *SpanMetricsProcessor) Shutdown(context.Context) error   { return nil }
+func (p *SpanMetricsProcessor) ForceFlush(context.Context) error { return nil }
+
+// sanitizeLabelName turns an attribute key such as http.route into a valid label name.
+func sanitizeLabelName(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (i == 0 || c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	BuildInfo     = "build_info"
+	UptimeSeconds = "uptime_seconds"
+)
+
+// WithStandardCollectors registers the Go runtime collector with the GC and scheduler
+// runtime/metrics rules, the process collector, a build_info gauge labelled with the
+// module version, VCS commit and Go version, and an uptime_seconds gauge. The default
+// Go and process collectors are replaced if they are registered; collectors already
+// registered by an earlier builder are reused.
+func WithStandardCollectors() BuilderOption {
+	return func(b *MetricsBuilder) {
+		b.standardCollectors = true
+	}
+}
+
+// WithStandardNamespace prefixes build_info and uptime_seconds registered by
+// WithStandardCollectors with namespace, e.g. myservice_build_info.
+func WithStandardNamespace(namespace string) BuilderOption {
+	return func(b *MetricsBuilder) {
+		b.namespace = namespace
+	}
+}
+
+func (b *MetricsBuilder) registerStandardCollectors() {
+	b.registerer.Unregister(collectors.NewGoCollector())
+	b.registerer.Unregister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	version, commit, goVersion := buildInfo()
+	buildInfoOpts := prometheus.GaugeOpts{
+		Namespace:   b.namespace,
+		Name:        BuildInfo,
+		Help:        "A metric with a constant '1' value labelled by version, commit and Go version of the service",
+		ConstLabels: prometheus.Labels{"version": version, "commit": commit, "go_version": goVersion},
+	}
+	if gauge, ok := register(b, prometheus.Opts(buildInfoOpts), prometheus.NewGauge(buildInfoOpts)); ok {
+		gauge.Set(1)
+	}
+
+	start := time.Now()
+	uptimeOpts := prometheus.GaugeOpts{
+		Namespace: b.namespace,
+		Name:      UptimeSeconds,
+		Help:      "Time since the service started in seconds",
+	}
+	register(b, prometheus.Opts(uptimeOpts), prometheus.NewGaugeFunc(uptimeOpts, func() float64 {
+		return time.Since(start).Seconds()
+	}))
+
+	reuse(b, "go", collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsScheduler)))
+	reuse(b, "process", collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// buildInfo returns the main module version, VCS commit and Go version of the binary.
+func buildInfo() (version, commit, goVersion string) {
+	version, commit, goVersion = "unknown", "unknown", "unknown"
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	goVersion = info.GoVersion
+	if info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			commit = setting.Value
+		}
+	}
+	return
+}
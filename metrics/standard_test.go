@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStandardCollectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []BuilderOption
+		wantPrefix string
+	}{
+		{"without namespace", []BuilderOption{WithStandardCollectors()}, ""},
+		{"with namespace", []BuilderOption{WithStandardCollectors(), WithStandardNamespace("checkout")}, "checkout_"},
+		{"namespace given first", []BuilderOption{WithStandardNamespace("checkout"), WithStandardCollectors()}, "checkout_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			opts := append([]BuilderOption{WithRegisterer(reg)}, tt.opts...)
+			// A second builder on the same registry reuses the collectors.
+			for i := 0; i < 2; i++ {
+				if _, err := NewMetricsBuilder(opts...).Build(); err != nil {
+					t.Fatalf("Build %d: %v", i+1, err)
+				}
+			}
+
+			for _, name := range []string{tt.wantPrefix + BuildInfo, tt.wantPrefix + UptimeSeconds, "go_goroutines", "process_start_time_seconds"} {
+				if len(gathered(t, reg, name)) == 0 {
+					t.Errorf("%s not registered", name)
+				}
+			}
+		})
+	}
+}
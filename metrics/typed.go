@@ -0,0 +1,276 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Counter is a counter bound to a label struct L, so label names, order and count are
+// checked by the compiler instead of at run time. Each exported field of L is a label
+// named by its `label` tag, or the snake_case field name; `label:"-"` skips the field.
+// Fields must be strings, bools, integers or implement fmt.Stringer:
+//
+//	type HTTPLabels struct {
+//		Route  string `label:"route"`
+//		Status int    `label:"status"`
+//	}
+//
+//	requests := metrics.NewCounter[HTTPLabels](b, "http_requests_total", "Total number of HTTP requests")
+//	requests.Inc(ctx, HTTPLabels{Route: "/user", Status: 200})
+//
+// Typed metrics are registered through the builder, so they are also available in the
+// Metrics maps and their errors are reported by Build. Handles of metrics that failed
+// to register, including a name already added with other labels, do nothing.
+type Counter[L any] struct {
+	vec    *CounterVec
+	labels *labelEncoder
+}
+
+// NewCounter adds a counter with the labels of L to b.
+func NewCounter[L any](b *MetricsBuilder, name, help string, opts ...MetricOption) *Counter[L] {
+	labels := b.labelEncoder(name, reflect.TypeOf((*L)(nil)).Elem())
+	if labels == nil {
+		return &Counter[L]{}
+	}
+	vec := b.addCounter(name, help, labels.names, opts)
+	if vec == nil {
+		return &Counter[L]{}
+	}
+	return &Counter[L]{vec: vec, labels: labels}
+}
+
+// Inc increments the counter, attaching the sampled span in ctx as an exemplar.
+func (c *Counter[L]) Inc(ctx context.Context, labels L) {
+	if c.vec != nil {
+		IncWithTrace(ctx, c.vec.WithLabelValues(c.labels.values(labels)...))
+	}
+}
+
+// Add adds v to the counter, attaching the sampled span in ctx as an exemplar.
+func (c *Counter[L]) Add(ctx context.Context, labels L, v float64) {
+	if c.vec == nil {
+		return
+	}
+	counter := c.vec.WithLabelValues(c.labels.values(labels)...)
+	if exemplar := exemplarLabels(ctx); exemplar != nil {
+		if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+			adder.AddWithExemplar(v, exemplar)
+			return
+		}
+	}
+	counter.Add(v)
+}
+
+// Vec returns the underlying collector, or nil if it failed to register.
+func (c *Counter[L]) Vec() *CounterVec {
+	return c.vec
+}
+
+// Histogram is a histogram with the labels of L.
+type Histogram[L any] struct {
+	vec    *HistogramVec
+	labels *labelEncoder
+}
+
+// NewHistogram adds a histogram with the labels of L to b.
+func NewHistogram[L any](b *MetricsBuilder, name, help string, buckets []float64, opts ...MetricOption) *Histogram[L] {
+	labels := b.labelEncoder(name, reflect.TypeOf((*L)(nil)).Elem())
+	if labels == nil {
+		return &Histogram[L]{}
+	}
+	vec := b.addHistogram(name, help, buckets, labels.names, opts)
+	if vec == nil {
+		return &Histogram[L]{}
+	}
+	return &Histogram[L]{vec: vec, labels: labels}
+}
+
+// Observe records v, attaching the sampled span in ctx as an exemplar.
+func (h *Histogram[L]) Observe(ctx context.Context, labels L, v float64) {
+	if h.vec != nil {
+		ObserveWithTrace(ctx, h.vec.WithLabelValues(h.labels.values(labels)...), v)
+	}
+}
+
+// Vec returns the underlying collector, or nil if it failed to register.
+func (h *Histogram[L]) Vec() *HistogramVec {
+	return h.vec
+}
+
+// Gauge is a gauge with the labels of L.
+type Gauge[L any] struct {
+	vec    *GaugeVec
+	labels *labelEncoder
+}
+
+// NewGauge adds a gauge with the labels of L to b.
+func NewGauge[L any](b *MetricsBuilder, name, help string, opts ...MetricOption) *Gauge[L] {
+	labels := b.labelEncoder(name, reflect.TypeOf((*L)(nil)).Elem())
+	if labels == nil {
+		return &Gauge[L]{}
+	}
+	vec := b.addGauge(name, help, labels.names, opts)
+	if vec == nil {
+		return &Gauge[L]{}
+	}
+	return &Gauge[L]{vec: vec, labels: labels}
+}
+
+// Set sets the gauge to v. Gauges have no exemplars; ctx is taken for symmetry with
+// Counter and Histogram.
+func (g *Gauge[L]) Set(ctx context.Context, labels L, v float64) {
+	if g.vec != nil {
+		g.vec.WithLabelValues(g.labels.values(labels)...).Set(v)
+	}
+}
+
+// Add adds v, which may be negative, to the gauge.
+func (g *Gauge[L]) Add(ctx context.Context, labels L, v float64) {
+	if g.vec != nil {
+		g.vec.WithLabelValues(g.labels.values(labels)...).Add(v)
+	}
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge[L]) Inc(ctx context.Context, labels L) {
+	g.Add(ctx, labels, 1)
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge[L]) Dec(ctx context.Context, labels L) {
+	g.Add(ctx, labels, -1)
+}
+
+// Vec returns the underlying collector, or nil if it failed to register.
+func (g *Gauge[L]) Vec() *GaugeVec {
+	return g.vec
+}
+
+// Summary is a summary with the labels of L.
+type Summary[L any] struct {
+	vec    *SummaryVec
+	labels *labelEncoder
+}
+
+// NewSummary adds a summary with the labels of L to b.
+func NewSummary[L any](b *MetricsBuilder, name, help string, opts ...MetricOption) *Summary[L] {
+	labels := b.labelEncoder(name, reflect.TypeOf((*L)(nil)).Elem())
+	if labels == nil {
+		return &Summary[L]{}
+	}
+	vec := b.addSummary(name, help, labels.names, opts)
+	if vec == nil {
+		return &Summary[L]{}
+	}
+	return &Summary[L]{vec: vec, labels: labels}
+}
+
+// Observe records v. Summaries have no exemplars; ctx is taken for symmetry with Histogram.
+func (s *Summary[L]) Observe(ctx context.Context, labels L, v float64) {
+	if s.vec != nil {
+		s.vec.WithLabelValues(s.labels.values(labels)...).Observe(v)
+	}
+}
+
+// Vec returns the underlying collector, or nil if it failed to register.
+func (s *Summary[L]) Vec() *SummaryVec {
+	return s.vec
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// labelEncoder turns a label struct into label values in the order of names.
+type labelEncoder struct {
+	names  []string
+	fields []int
+}
+
+// labelEncoder builds the encoder of the label struct t, recording an error for Build if t is not supported.
+func (b *MetricsBuilder) labelEncoder(name string, t reflect.Type) *labelEncoder {
+	labels, err := newLabelEncoder(t)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("metrics: labels of %q: %w", name, err))
+		return nil
+	}
+	return labels
+}
+
+func newLabelEncoder(t reflect.Type) (*labelEncoder, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+
+	e := &labelEncoder{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("label")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+		if !field.Type.Implements(stringerType) {
+			switch field.Type.Kind() {
+			case reflect.String, reflect.Bool,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return nil, fmt.Errorf("field %s has unsupported type %s", field.Name, field.Type)
+			}
+		}
+		e.names = append(e.names, name)
+		e.fields = append(e.fields, i)
+	}
+	return e, nil
+}
+
+func (e *labelEncoder) values(labels any) []string {
+	v := reflect.ValueOf(labels)
+	values := make([]string, len(e.fields))
+	for i, index := range e.fields {
+		field := v.Field(index)
+		if s, ok := field.Interface().(fmt.Stringer); ok {
+			values[i] = s.String()
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			values[i] = field.String()
+		case reflect.Bool:
+			values[i] = strconv.FormatBool(field.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values[i] = strconv.FormatInt(field.Int(), 10)
+		default:
+			values[i] = strconv.FormatUint(field.Uint(), 10)
+		}
+	}
+	return values
+}
+
+// snakeCase converts a Go field name such as StatusCode to status_code.
+func snakeCase(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type method int
+
+func (m method) String() string { return [...]string{"GET", "POST"}[m] }
+
+type httpLabels struct {
+	Route      string `label:"route"`
+	StatusCode int
+	Cached     bool
+	Method     method
+	Internal   string `label:"-"`
+	tenant     string
+}
+
+func sampledContext() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"Route", "route"},
+		{"StatusCode", "status_code"},
+		{"HTTPMethod", "http_method"},
+		{"UserID", "user_id"},
+		{"already_snake", "already_snake"},
+	}
+	for _, tt := range tests {
+		if got := snakeCase(tt.name); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTypedHandles(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	b := NewMetricsBuilder(WithRegisterer(reg))
+	requests := NewCounter[httpLabels](b, "http_requests_total", "Requests")
+	latency := NewHistogram[httpLabels](b, "http_request_seconds", "Latency", []float64{0.1, 1})
+	inFlight := NewGauge[httpLabels](b, "http_in_flight", "In flight")
+	sizes := NewSummary[httpLabels](b, "http_response_bytes", "Sizes")
+	m, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if m.Counters["http_requests_total"] != requests.Vec() {
+		t.Error("typed counter is not stored in Metrics.Counters")
+	}
+
+	ctx := sampledContext()
+	labels := httpLabels{Route: "/user", StatusCode: 200, Cached: true, Method: 1, Internal: "x", tenant: "acme"}
+	requests.Inc(ctx, labels)
+	requests.Add(ctx, labels, 2)
+	latency.Observe(ctx, labels, 0.5)
+	inFlight.Inc(ctx, labels)
+	inFlight.Inc(ctx, labels)
+	inFlight.Dec(ctx, labels)
+	sizes.Observe(ctx, labels, 512)
+
+	const wantLabels = "true,POST,/user,200"
+	for _, name := range []string{"http_requests_total", "http_request_seconds", "http_in_flight", "http_response_bytes"} {
+		series := gathered(t, reg, name)
+		if len(series) != 1 || labelValues(series[0]) != wantLabels {
+			t.Fatalf("%s series = %v, want one with labels %s", name, series, wantLabels)
+		}
+	}
+
+	counter := gathered(t, reg, "http_requests_total")[0].GetCounter()
+	if counter.GetValue() != 3 {
+		t.Errorf("counter = %v, want 3", counter.GetValue())
+	}
+	if exemplar := counter.GetExemplar(); exemplar == nil || len(exemplar.GetLabel()) != 2 || exemplar.GetValue() != 2 {
+		t.Errorf("counter exemplar = %v, want the trace of the last Add", exemplar)
+	}
+	if got := gathered(t, reg, "http_in_flight")[0].GetGauge().GetValue(); got != 1 {
+		t.Errorf("gauge = %v, want 1", got)
+	}
+	if got := gathered(t, reg, "http_request_seconds")[0].GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("histogram count = %v, want 1", got)
+	}
+}
+
+func TestTypedHandleErrors(t *testing.T) {
+	type unsupported struct {
+		Ratio float64
+	}
+	type otherLabels struct {
+		Queue string
+	}
+
+	tests := []struct {
+		name    string
+		add     func(b *MetricsBuilder) func()
+		wantErr string
+	}{
+		{
+			name: "unsupported label type",
+			add: func(b *MetricsBuilder) func() {
+				c := NewCounter[unsupported](b, "jobs_total", "Jobs")
+				return func() { c.Inc(context.Background(), unsupported{Ratio: 0.5}) }
+			},
+			wantErr: "field Ratio has unsupported type float64",
+		},
+		{
+			name: "labels not a struct",
+			add: func(b *MetricsBuilder) func() {
+				h := NewHistogram[string](b, "jobs_seconds", "Jobs", nil)
+				return func() { h.Observe(context.Background(), "x", 1) }
+			},
+			wantErr: "string is not a struct",
+		},
+		{
+			name: "name added with other labels",
+			add: func(b *MetricsBuilder) func() {
+				NewCounter[httpLabels](b, "jobs_total", "Jobs")
+				c := NewCounter[otherLabels](b, "jobs_total", "Jobs")
+				return func() { c.Add(context.Background(), otherLabels{Queue: "q"}, 1) }
+			},
+			wantErr: "jobs_total",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMetricsBuilder(WithRegisterer(prometheus.NewRegistry()))
+			use := tt.add(b)
+			// Handles that failed to register do nothing.
+			use()
+			if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Build error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTypedHandlesAreGuarded(t *testing.T) {
+	type routeLabels struct {
+		Route string
+	}
+	reg := prometheus.NewRegistry()
+	b := NewMetricsBuilder(WithRegisterer(reg))
+	requests := NewCounter[routeLabels](b, "requests_total", "Requests", WithGuard(WithLabelAllowlist("route", "/user")))
+	if _, err := b.Build(); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	requests.Inc(context.Background(), routeLabels{Route: "/user"})
+	requests.Inc(context.Background(), routeLabels{Route: "/admin/secret"})
+
+	var got []string
+	for _, series := range gathered(t, reg, "requests_total") {
+		got = append(got, labelValues(series))
+	}
+	if strings.Join(got, " ") != "/user "+OverflowLabelValue {
+		t.Errorf("series = %v, want [/user %s]", got, OverflowLabelValue)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// The vectors stored in Metrics pass label values through the CardinalityGuard of
+// their metric, if it was added WithGuard, so the guard also applies to direct use
+// of the maps. Vectors returned by CurryWith are not guarded.
+
+// CounterVec is a prometheus.CounterVec guarded by the CardinalityGuard of its metric.
+type CounterVec struct {
+	*prometheus.CounterVec
+	guard *CardinalityGuard
+}
+
+// WithLabelValues returns the counter for the guarded label values.
+func (v *CounterVec) WithLabelValues(lvs ...string) prometheus.Counter {
+	return v.CounterVec.WithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// GetMetricWithLabelValues returns the counter for the guarded label values.
+func (v *CounterVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Counter, error) {
+	return v.CounterVec.GetMetricWithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// With returns the counter for the guarded labels.
+func (v *CounterVec) With(labels prometheus.Labels) prometheus.Counter {
+	return v.CounterVec.With(v.guard.Labels(labels))
+}
+
+// GetMetricWith returns the counter for the guarded labels.
+func (v *CounterVec) GetMetricWith(labels prometheus.Labels) (prometheus.Counter, error) {
+	return v.CounterVec.GetMetricWith(v.guard.Labels(labels))
+}
+
+// HistogramVec is a prometheus.HistogramVec guarded by the CardinalityGuard of its metric.
+type HistogramVec struct {
+	*prometheus.HistogramVec
+	guard *CardinalityGuard
+}
+
+// WithLabelValues returns the histogram for the guarded label values.
+func (v *HistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	return v.HistogramVec.WithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// GetMetricWithLabelValues returns the histogram for the guarded label values.
+func (v *HistogramVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error) {
+	return v.HistogramVec.GetMetricWithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// With returns the histogram for the guarded labels.
+func (v *HistogramVec) With(labels prometheus.Labels) prometheus.Observer {
+	return v.HistogramVec.With(v.guard.Labels(labels))
+}
+
+// GetMetricWith returns the histogram for the guarded labels.
+func (v *HistogramVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	return v.HistogramVec.GetMetricWith(v.guard.Labels(labels))
+}
+
+// GaugeVec is a prometheus.GaugeVec guarded by the CardinalityGuard of its metric.
+type GaugeVec struct {
+	*prometheus.GaugeVec
+	guard *CardinalityGuard
+}
+
+// WithLabelValues returns the gauge for the guarded label values.
+func (v *GaugeVec) WithLabelValues(lvs ...string) prometheus.Gauge {
+	return v.GaugeVec.WithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// GetMetricWithLabelValues returns the gauge for the guarded label values.
+func (v *GaugeVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Gauge, error) {
+	return v.GaugeVec.GetMetricWithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// With returns the gauge for the guarded labels.
+func (v *GaugeVec) With(labels prometheus.Labels) prometheus.Gauge {
+	return v.GaugeVec.With(v.guard.Labels(labels))
+}
+
+// GetMetricWith returns the gauge for the guarded labels.
+func (v *GaugeVec) GetMetricWith(labels prometheus.Labels) (prometheus.Gauge, error) {
+	return v.GaugeVec.GetMetricWith(v.guard.Labels(labels))
+}
+
+// SummaryVec is a prometheus.SummaryVec guarded by the CardinalityGuard of its metric.
+type SummaryVec struct {
+	*prometheus.SummaryVec
+	guard *CardinalityGuard
+}
+
+// WithLabelValues returns the summary for the guarded label values.
+func (v *SummaryVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	return v.SummaryVec.WithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// GetMetricWithLabelValues returns the summary for the guarded label values.
+func (v *SummaryVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error) {
+	return v.SummaryVec.GetMetricWithLabelValues(v.guard.Values(lvs...)...)
+}
+
+// With returns the summary for the guarded labels.
+func (v *SummaryVec) With(labels prometheus.Labels) prometheus.Observer {
+	return v.SummaryVec.With(v.guard.Labels(labels))
+}
+
+// GetMetricWith returns the summary for the guarded labels.
+func (v *SummaryVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	return v.SummaryVec.GetMetricWith(v.guard.Labels(labels))
+}
@@ -2,13 +2,20 @@ package otelBuilder
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	apw_logging "github.com/kyon1313/observability/logs"
+	"github.com/kyon1313/observability/metrics"
 	apw_tracing "github.com/kyon1313/observability/tracing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -25,6 +32,18 @@ type OtelTracingBuilder struct {
 	traceOpts          []trace.BatchSpanProcessorOption
 	traceExporterOpts  []otlptracehttp.Option
 	useConsoleExporter bool
+	spanProcessors     []trace.SpanProcessor
+	spanMetrics        bool
+	spanMetricsReg     prometheus.Registerer
+	spanMetricsOpts    []metrics.SpanMetricsOption
+	meterRegisterer    prometheus.Registerer
+	otlpMeter          bool
+	otlpMeterInterval  time.Duration
+	otlpMeterOpts      []otlpmetrichttp.Option
+
+	// tracerProvider and meterProvider are created by Build and stopped by Shutdown.
+	tracerProvider *trace.TracerProvider
+	meterProvider  *metric.MeterProvider
 }
 
 func NewOtelTracingBuilder() *OtelTracingBuilder {
@@ -76,6 +95,21 @@ func (b *OtelTracingBuilder) WithConsoleExporter() *OtelTracingBuilder {
 	return b
 }
 
+// WithBaggageAttributes copies the given baggage keys, such as tenant_id, onto every span as attributes.
+func (b *OtelTracingBuilder) WithBaggageAttributes(keys ...string) *OtelTracingBuilder {
+	b.spanProcessors = append(b.spanProcessors, apw_tracing.NewBaggageSpanProcessor(keys...))
+	return b
+}
+
+// WithSpanMetrics records rate, error and duration metrics for every ended span on reg,
+// or on the default registry if reg is nil.
+func (b *OtelTracingBuilder) WithSpanMetrics(reg prometheus.Registerer, opts ...metrics.SpanMetricsOption) *OtelTracingBuilder {
+	b.spanMetrics = true
+	b.spanMetricsReg = reg
+	b.spanMetricsOpts = append(b.spanMetricsOpts, opts...)
+	return b
+}
+
 func (b *OtelTracingBuilder) Build(ctx context.Context, l apw_logging.OtelLogging) (apw_tracing.OtelTracing, error) {
 	var traceExporter trace.SpanExporter
 	var err error
@@ -93,14 +127,50 @@ func (b *OtelTracingBuilder) Build(ctx context.Context, l apw_logging.OtelLoggin
 	}
 
 	resourceOpts := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(b.serviceName))
-	tracerProvider := trace.NewTracerProvider(
-		trace.WithBatcher(traceExporter, b.traceOpts...),
-		trace.WithResource(resourceOpts),
-	)
+	providerOpts := []trace.TracerProviderOption{trace.WithResource(resourceOpts)}
+	for _, processor := range b.spanProcessors {
+		providerOpts = append(providerOpts, trace.WithSpanProcessor(processor))
+	}
+	if b.spanMetrics {
+		spanMetrics, err := metrics.NewSpanMetricsProcessor(b.spanMetricsReg, b.spanMetricsOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create span metrics processor: %w", err)
+		}
+		providerOpts = append(providerOpts, trace.WithSpanProcessor(spanMetrics))
+	}
+	providerOpts = append(providerOpts, trace.WithBatcher(traceExporter, b.traceOpts...))
+
+	meterProvider, err := b.newMeterProvider(ctx, resourceOpts)
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider := trace.NewTracerProvider(providerOpts...)
+	b.tracerProvider, b.meterProvider = tracerProvider, meterProvider
 
 	// Set global tracer provider
 	otel.SetTracerProvider(tracerProvider)
 
+	// Set global meter provider, sharing the tracer provider's resource
+	if meterProvider != nil {
+		otel.SetMeterProvider(meterProvider)
+	}
+
+	// Propagate trace context and baggage across process boundaries
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
 	// Return tracing instance
 	return apw_tracing.NewTracing(tracerProvider.Tracer(b.serviceName), l), nil
 }
+
+// Shutdown flushes and stops the tracer and meter providers created by Build, so that
+// buffered spans and the last OTLP metrics are exported before the process exits.
+func (b *OtelTracingBuilder) Shutdown(ctx context.Context) error {
+	var errs []error
+	if b.tracerProvider != nil {
+		errs = append(errs, b.tracerProvider.Shutdown(ctx))
+	}
+	if b.meterProvider != nil {
+		errs = append(errs, b.meterProvider.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
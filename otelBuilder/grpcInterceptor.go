@@ -0,0 +1,371 @@
+package otelBuilder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+	"github.com/kyon1313/observability/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpc_codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCInterceptor builds server and client interceptors that trace every gRPC call
+// and, when metrics are configured, record request counts and latencies.
+type GRPCInterceptor struct {
+	l             apw_logging.OtelLogging
+	tracer        trace.Tracer
+	metrics       *metrics.Metrics
+	messageEvents bool
+}
+
+func NewGRPCInterceptor(l apw_logging.OtelLogging, tracer trace.Tracer) *GRPCInterceptor {
+	return &GRPCInterceptor{l: l, tracer: tracer}
+}
+
+// WithMetrics records request counts and latencies into metrics registered with MetricsBuilder.AddGRPCMetrics.
+func (g *GRPCInterceptor) WithMetrics(m *metrics.Metrics) *GRPCInterceptor {
+	g.metrics = m
+	return g
+}
+
+// WithMessageEvents adds a span event for every message sent or received.
+func (g *GRPCInterceptor) WithMessageEvents() *GRPCInterceptor {
+	g.messageEvents = true
+	return g
+}
+
+// UnaryServerInterceptor traces unary calls handled by the server.
+func (g *GRPCInterceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := g.startSpan(extractMetadata(ctx), info.FullMethod, trace.SpanKindServer)
+		start := time.Now()
+
+		g.addMessageEvent(span, semconv.MessageTypeReceived, 1, req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			g.addMessageEvent(span, semconv.MessageTypeSent, 1, resp)
+		}
+
+		g.finish(span, info.FullMethod, trace.SpanKindServer, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor traces streaming calls handled by the server.
+func (g *GRPCInterceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := g.startSpan(extractMetadata(ss.Context()), info.FullMethod, trace.SpanKindServer)
+		start := time.Now()
+
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx, g: g, span: span})
+
+		g.finish(span, info.FullMethod, trace.SpanKindServer, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor traces unary calls made by the client.
+func (g *GRPCInterceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := g.startSpan(ctx, method, trace.SpanKindClient)
+		start := time.Now()
+
+		g.addMessageEvent(span, semconv.MessageTypeSent, 1, req)
+		err := invoker(injectMetadata(ctx), method, req, reply, cc, opts...)
+		if err == nil {
+			g.addMessageEvent(span, semconv.MessageTypeReceived, 1, reply)
+		}
+
+		g.finish(span, method, trace.SpanKindClient, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor traces streaming calls made by the client. The span ends
+// once the stream returns an error or io.EOF from RecvMsg, after the single response
+// of a call without server streaming, or when the call context is done, so a caller
+// that stops receiving early must cancel it as gRPC requires anyway.
+func (g *GRPCInterceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := g.startSpan(ctx, method, trace.SpanKindClient)
+		start := time.Now()
+
+		cs, err := streamer(injectMetadata(ctx), desc, cc, method, opts...)
+		if err != nil {
+			g.finish(span, method, trace.SpanKindClient, start, err)
+			return nil, err
+		}
+
+		stream := &tracedClientStream{
+			ClientStream:  cs,
+			g:             g,
+			span:          span,
+			method:        method,
+			start:         start,
+			serverStreams: desc.ServerStreams,
+			done:          make(chan struct{}),
+		}
+		// A context that is never done, such as context.Background, needs no watcher.
+		if ctx.Done() != nil {
+			go func() {
+				select {
+				case <-ctx.Done():
+					stream.end(status.FromContextError(ctx.Err()).Err())
+				case <-stream.done:
+				}
+			}()
+		}
+		return stream, nil
+	}
+}
+
+func (g *GRPCInterceptor) startSpan(ctx context.Context, fullMethod string, kind trace.SpanKind) (context.Context, trace.Span) {
+	service, method := splitFullMethod(fullMethod)
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	if service != "" {
+		attrs = append(attrs, semconv.RPCService(service))
+	}
+	if method != "" {
+		attrs = append(attrs, semconv.RPCMethod(method))
+	}
+
+	return g.tracer.Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (g *GRPCInterceptor) finish(span trace.Span, fullMethod string, kind trace.SpanKind, start time.Time, err error) {
+	code := status.Code(err)
+	if err != nil {
+		span.RecordError(err)
+	}
+	SetSpanGRPCStatus(span, kind, code, status.Convert(err).Message())
+	span.End()
+
+	duration := time.Since(start)
+	g.observe(fullMethod, kind, code, duration)
+
+	g.l.Debugw("gRPC call completed",
+		"request_id", span.SpanContext().TraceID().String(),
+		"method", fullMethod,
+		"code", code.String(),
+		"duration", duration,
+	)
+}
+
+func (g *GRPCInterceptor) observe(fullMethod string, kind trace.SpanKind, code grpc_codes.Code, duration time.Duration) {
+	if g.metrics == nil {
+		return
+	}
+
+	counterName, histogramName := metrics.GRPCServerRequestsTotal, metrics.GRPCServerRequestDuration
+	if kind == trace.SpanKindClient {
+		counterName, histogramName = metrics.GRPCClientRequestsTotal, metrics.GRPCClientRequestDuration
+	}
+
+	service, method := splitFullMethod(fullMethod)
+	if counter, ok := g.metrics.Counters[counterName]; ok {
+		counter.WithLabelValues(service, method, code.String()).Inc()
+	}
+	if histogram, ok := g.metrics.Histograms[histogramName]; ok {
+		histogram.WithLabelValues(service, method).Observe(duration.Seconds())
+	}
+}
+
+func (g *GRPCInterceptor) addMessageEvent(span trace.Span, messageType attribute.KeyValue, id int, msg any) {
+	if !g.messageEvents || !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{messageType, semconv.MessageID(id)}
+	if p, ok := msg.(proto.Message); ok {
+		attrs = append(attrs, semconv.MessageUncompressedSize(proto.Size(p)))
+	}
+	span.AddEvent("message", trace.WithAttributes(attrs...))
+}
+
+// GRPCStatus maps a gRPC status code to a span status following the semantic conventions:
+// clients treat every non-OK code as an error, while servers only flag codes that
+// indicate a server-side failure.
+func GRPCStatus(code grpc_codes.Code, kind trace.SpanKind) (codes.Code, string) {
+	if code == grpc_codes.OK {
+		return codes.Unset, ""
+	}
+	if kind != trace.SpanKindServer {
+		return codes.Error, code.String()
+	}
+
+	switch code {
+	case grpc_codes.Unknown, grpc_codes.DeadlineExceeded, grpc_codes.Unimplemented,
+		grpc_codes.Internal, grpc_codes.Unavailable, grpc_codes.DataLoss:
+		return codes.Error, code.String()
+	default:
+		return codes.Unset, ""
+	}
+}
+
+// SetSpanGRPCStatus records code as rpc.grpc.status_code and sets the matching span status.
+// An Unset status leaves an earlier Ok or Error untouched.
+func SetSpanGRPCStatus(span trace.Span, kind trace.SpanKind, code grpc_codes.Code, description string) {
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	status, fallback := GRPCStatus(code, kind)
+	if description == "" {
+		description = fallback
+	}
+	if status != codes.Unset {
+		span.SetStatus(status, description)
+	}
+}
+
+// splitFullMethod splits "/package.Service/Method" into its service and method parts.
+func splitFullMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+func extractMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+func injectMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx            context.Context
+	g              *GRPCInterceptor
+	span           trace.Span
+	received, sent int
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *tracedServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		s.g.addMessageEvent(s.span, semconv.MessageTypeReceived, s.received, m)
+	}
+	return err
+}
+
+func (s *tracedServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.g.addMessageEvent(s.span, semconv.MessageTypeSent, s.sent, m)
+	}
+	return err
+}
+
+type tracedClientStream struct {
+	grpc.ClientStream
+	g              *GRPCInterceptor
+	span           trace.Span
+	method         string
+	start          time.Time
+	received, sent int
+	serverStreams  bool
+	once           sync.Once
+	done           chan struct{}
+}
+
+func (s *tracedClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.received++
+		s.g.addMessageEvent(s.span, semconv.MessageTypeReceived, s.received, m)
+		// Without server streaming, gRPC completes the call after the one response
+		// and RecvMsg never returns io.EOF.
+		if !s.serverStreams {
+			s.end(nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.end(nil)
+	default:
+		s.end(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.g.addMessageEvent(s.span, semconv.MessageTypeSent, s.sent, m)
+	} else if !errors.Is(err, io.EOF) {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *tracedClientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.g.finish(s.span, s.method, trace.SpanKindClient, s.start, err)
+	})
+}
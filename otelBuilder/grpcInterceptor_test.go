@@ -0,0 +1,120 @@
+package otelBuilder
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+)
+
+// fakeClientStream answers RecvMsg like grpc's clientStream: responses, then io.EOF
+// for server streams only.
+type fakeClientStream struct {
+	grpc.ClientStream
+	ctx       context.Context
+	responses int
+	eof       bool
+}
+
+func (s *fakeClientStream) Context() context.Context { return s.ctx }
+func (s *fakeClientStream) SendMsg(any) error        { return nil }
+func (s *fakeClientStream) CloseSend() error         { return nil }
+
+func (s *fakeClientStream) RecvMsg(any) error {
+	if s.responses == 0 {
+		if s.eof {
+			return io.EOF
+		}
+		panic("RecvMsg called after the call completed")
+	}
+	s.responses--
+	return nil
+}
+
+func newTestInterceptor(t *testing.T) (*GRPCInterceptor, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return NewGRPCInterceptor(apw_logging.NewOtelLogging(), provider.Tracer("grpc_test")), recorder
+}
+
+func TestStreamClientInterceptorEndsSpan(t *testing.T) {
+	tests := []struct {
+		name    string
+		desc    grpc.StreamDesc
+		stream  *fakeClientStream
+		receive int
+	}{
+		{"client streaming", grpc.StreamDesc{ClientStreams: true}, &fakeClientStream{responses: 1}, 1},
+		{"server streaming", grpc.StreamDesc{ServerStreams: true}, &fakeClientStream{responses: 2, eof: true}, 3},
+		{"bidirectional", grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, &fakeClientStream{responses: 1, eof: true}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, recorder := newTestInterceptor(t)
+			streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+				tt.stream.ctx = ctx
+				return tt.stream, nil
+			}
+
+			cs, err := g.StreamClientInterceptor()(context.Background(), &tt.desc, nil, "/upload.Uploader/Upload", streamer)
+			if err != nil {
+				t.Fatalf("interceptor: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := cs.SendMsg("chunk"); err != nil {
+					t.Fatalf("SendMsg: %v", err)
+				}
+			}
+			cs.CloseSend()
+			for i := 0; i < tt.receive; i++ {
+				if len(recorder.Ended()) != 0 {
+					t.Fatalf("span ended before receive %d", i+1)
+				}
+				cs.RecvMsg(new(string))
+			}
+
+			ended := recorder.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("ended spans = %d, want 1", len(ended))
+			}
+			if ended[0].Name() != "upload.Uploader/Upload" {
+				t.Errorf("span name = %q", ended[0].Name())
+			}
+			if code := ended[0].Status().Code; code != codes.Unset {
+				t.Errorf("status = %v, want Unset", code)
+			}
+		})
+	}
+}
+
+func TestStreamClientInterceptorEndsSpanOnCancel(t *testing.T) {
+	g, recorder := newTestInterceptor(t)
+	stream := &fakeClientStream{responses: 1}
+	streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream.ctx = ctx
+		return stream, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := g.StreamClientInterceptor()(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/feed.Feed/Watch", streamer); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	cancel()
+
+	for i := 0; i < 1000 && len(recorder.Ended()) == 0; i++ {
+		<-time.After(time.Millisecond)
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error {
+		t.Fatalf("want one failed span after cancellation, got %d", len(ended))
+	}
+}
@@ -0,0 +1,65 @@
+package otelBuilder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const defaultMetricExportInterval = time.Minute
+
+// WithPrometheusMeter sets up an OpenTelemetry MeterProvider whose instruments are
+// collected on reg, so they are served by the existing /metrics handler. A nil reg
+// uses the default registry.
+func (b *OtelTracingBuilder) WithPrometheusMeter(reg prometheus.Registerer) *OtelTracingBuilder {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	b.meterRegisterer = reg
+	return b
+}
+
+// WithOTLPMeter pushes the OpenTelemetry instruments over OTLP/HTTP every interval,
+// or every minute if interval is 0. Shutdown pushes them a last time.
+func (b *OtelTracingBuilder) WithOTLPMeter(interval time.Duration, opts ...otlpmetrichttp.Option) *OtelTracingBuilder {
+	if interval == 0 {
+		interval = defaultMetricExportInterval
+	}
+	b.otlpMeter = true
+	b.otlpMeterInterval = interval
+	b.otlpMeterOpts = opts
+	return b
+}
+
+// newMeterProvider creates the MeterProvider with the configured readers, or returns
+// nil if neither WithPrometheusMeter nor WithOTLPMeter was called.
+func (b *OtelTracingBuilder) newMeterProvider(ctx context.Context, res *resource.Resource) (*metric.MeterProvider, error) {
+	opts := []metric.Option{metric.WithResource(res)}
+
+	if b.meterRegisterer != nil {
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(b.meterRegisterer))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Prometheus metric reader: %w", err)
+		}
+		opts = append(opts, metric.WithReader(exporter))
+	}
+
+	if b.otlpMeter {
+		exporter, err := otlpmetrichttp.New(ctx, b.otlpMeterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		opts = append(opts, metric.WithReader(metric.NewPeriodicReader(exporter, metric.WithInterval(b.otlpMeterInterval))))
+	}
+
+	if len(opts) == 1 {
+		return nil, nil
+	}
+	return metric.NewMeterProvider(opts...), nil
+}
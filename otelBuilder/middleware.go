@@ -10,9 +10,11 @@ import (
 	"time"
 
 	apw_logging "github.com/kyon1313/observability/logs"
+	apw_tracing "github.com/kyon1313/observability/tracing"
 
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
@@ -24,16 +26,17 @@ func TracingMiddleware(l apw_logging.OtelLogging, tracer trace.Tracer) gin.Handl
 			return
 		}
 
-		ctx, span := tracer.Start(c.Request.Context(), fmt.Sprintf("HTTP %s %s", c.Request.Method, c.Request.URL.Path))
+		ctx, span := tracer.Start(c.Request.Context(), fmt.Sprintf("HTTP %s %s", c.Request.Method, c.Request.URL.Path), trace.WithSpanKind(trace.SpanKindServer))
 		defer span.End()
 
 		traceID := span.SpanContext().TraceID().String()
 		c.Set("X-Request-Id", traceID)
 		c.Header("X-Request-Id", traceID)
 
-		logRequestDetails(l, c, traceID)
+		log := l.WithContext(ctx)
+		logRequestDetails(log, c, traceID)
 
-		body, requestBody := readRequestBody(l, c, traceID)
+		body, requestBody := readRequestBody(log, c, traceID)
 		if requestBody != nil {
 			setSpanAttributes(span, "request.body", requestBody)
 		}
@@ -47,16 +50,17 @@ func TracingMiddleware(l apw_logging.OtelLogging, tracer trace.Tracer) gin.Handl
 		c.Next()
 
 		responseBody := w.body.String()
-		logResponseBody(l, traceID, responseBody)
+		logResponseBody(log, traceID, responseBody)
 
-		if w.statusCode >= 400 {
-			span.SetAttributes(attribute.Int("status.Code", 2))
-		} else {
+		if w.statusCode < 400 {
 			setSpanAttributes(span, "response.body", parseJSON(responseBody))
-			span.SetAttributes(attribute.Int("status.Code", 1))
 		}
 
-		span.SetAttributes(attribute.Int("http.status_code", w.statusCode))
+		span.SetAttributes(
+			semconv.HTTPRequestContentLength(len(body)),
+			semconv.HTTPResponseContentLength(w.body.Len()),
+		)
+		apw_tracing.SetSpanHTTPStatus(span, trace.SpanKindServer, w.statusCode)
 	}
 }
 
@@ -0,0 +1,112 @@
+package otelBuilder
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apw_errors "github.com/kyon1313/observability/errors"
+	apw_logging "github.com/kyon1313/observability/logs"
+	"github.com/kyon1313/observability/metrics"
+	apw_tracing "github.com/kyon1313/observability/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const problemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the error category to build the problem "type" URI.
+var ProblemTypeBase = "/problems/"
+
+// Problem is an RFC 7807 problem details body extended with the IDs a client can quote back.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// AbortWithProblem attaches err to the request and stops the handler chain.
+// ProblemMiddleware turns it into the response.
+func AbortWithProblem(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// ProblemMiddleware writes the last error attached to the request as an
+// application/problem+json response, records it on the server span and counts it in
+// the errors metric by category. It must run inside TracingMiddleware to see the span.
+func ProblemMiddleware(l apw_logging.OtelLogging, m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := NewProblem(c, err)
+		category := string(apw_errors.CategoryOf(err))
+
+		span := trace.SpanFromContext(c.Request.Context())
+		span.RecordError(err, trace.WithAttributes(apw_tracing.ExceptionAttributes(err)...))
+		span.SetAttributes(attribute.String("error.category", category))
+		apw_tracing.SetSpanHTTPStatus(span, trace.SpanKindServer, problem.Status)
+
+		metrics.CountError(m, c.FullPath(), category)
+		l.WithContext(c.Request.Context()).Error("Request failed: ", err)
+
+		body, marshalErr := json.Marshal(problem)
+		if marshalErr != nil {
+			c.Status(problem.Status)
+			return
+		}
+		c.Data(problem.Status, problemContentType, body)
+	}
+}
+
+// NewProblem builds the problem details for err. Classified errors expose their message
+// and code; internal and unclassified errors only expose a generic detail.
+func NewProblem(c *gin.Context, err error) Problem {
+	category := apw_errors.CategoryOf(err)
+	status := categoryStatus(category)
+
+	problem := Problem{
+		Type:      ProblemTypeBase + string(category),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    "An internal error occurred",
+		Instance:  c.Request.URL.Path,
+		RequestID: c.GetString("X-Request-Id"),
+	}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+		problem.TraceID = sc.TraceID().String()
+	}
+	if problem.RequestID == "" {
+		problem.RequestID = c.GetHeader("X-Request-Id")
+	}
+
+	if e, ok := apw_errors.As(err); ok && category != apw_errors.Internal {
+		problem.Detail = e.Message
+		problem.Code = e.Code
+	}
+	return problem
+}
+
+func categoryStatus(category apw_errors.Category) int {
+	switch category {
+	case apw_errors.NotFound:
+		return http.StatusNotFound
+	case apw_errors.Validation:
+		return http.StatusBadRequest
+	case apw_errors.Upstream:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
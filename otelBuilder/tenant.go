@@ -0,0 +1,100 @@
+package otelBuilder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+	apw_tenant "github.com/kyon1313/observability/tenant"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantResolver extracts the tenant of a request. It returns "" if the request has none.
+type TenantResolver func(c *gin.Context) string
+
+// TenantFromHeader resolves the tenant from a request header, e.g. X-Tenant-Id.
+func TenantFromHeader(name string) TenantResolver {
+	return func(c *gin.Context) string {
+		return c.GetHeader(name)
+	}
+}
+
+// TenantFromPathParam resolves the tenant from a route parameter, e.g. :tenant in /tenants/:tenant/users.
+func TenantFromPathParam(name string) TenantResolver {
+	return func(c *gin.Context) string {
+		return c.Param(name)
+	}
+}
+
+// TenantFromJWTClaim resolves the tenant from a claim of the bearer token in the
+// Authorization header. The token signature is not verified; authentication must
+// be enforced elsewhere.
+func TenantFromJWTClaim(claim string) TenantResolver {
+	return func(c *gin.Context) string {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			return ""
+		}
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return ""
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return ""
+		}
+
+		var claims map[string]any
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return ""
+		}
+		switch value := claims[claim].(type) {
+		case nil:
+			return ""
+		case string:
+			return value
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+}
+
+// FirstTenant tries each resolver in order and returns the first tenant found.
+func FirstTenant(resolvers ...TenantResolver) TenantResolver {
+	return func(c *gin.Context) string {
+		for _, resolve := range resolvers {
+			if tenantID := resolve(c); tenantID != "" {
+				return tenantID
+			}
+		}
+		return ""
+	}
+}
+
+// TenantMiddleware resolves the tenant of each request and stores it in the request
+// context and baggage. Register it before TracingMiddleware and build the tracer with
+// WithBaggageAttributes(apw_tenant.BaggageKey) so every span carries the tenant; the
+// tenant is also set on a span already active in the context.
+func TenantMiddleware(l apw_logging.OtelLogging, resolve TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := resolve(c)
+		if tenantID == "" {
+			c.Next()
+			return
+		}
+
+		ctx, err := apw_tenant.NewContext(c.Request.Context(), tenantID)
+		if err != nil {
+			l.Warnf("tenant %q not propagated in baggage: %v", tenantID, err)
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String(apw_tenant.BaggageKey, tenantID))
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
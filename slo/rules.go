@@ -0,0 +1,125 @@
+package slo
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// errorRatioRecord names the recorded ratio of bad events of an SLO over a window.
+const errorRatioRecord = "slo:sli_error:ratio_rate"
+
+// burnRateAlert pairs a long and a short window over which the error budget must burn
+// at least as fast as consuming budgetSpent of it within the long window. The short
+// window makes the alert reset quickly once the burn stops.
+type burnRateAlert struct {
+	severity    string
+	long, short time.Duration
+	budgetSpent float64
+	forDuration time.Duration
+}
+
+// burnRateAlerts are the multi-window, multi-burn-rate alerts of the Google SRE workbook.
+// Over a 30 day window they fire at burn rates of 14.4, 6, 3 and 1.
+var burnRateAlerts = []burnRateAlert{
+	{severity: "page", long: time.Hour, short: 5 * time.Minute, budgetSpent: 0.02, forDuration: 2 * time.Minute},
+	{severity: "page", long: 6 * time.Hour, short: 30 * time.Minute, budgetSpent: 0.05, forDuration: 15 * time.Minute},
+	{severity: "ticket", long: 24 * time.Hour, short: 2 * time.Hour, budgetSpent: 0.1, forDuration: time.Hour},
+	{severity: "ticket", long: 3 * 24 * time.Hour, short: 6 * time.Hour, budgetSpent: 0.1, forDuration: 3 * time.Hour},
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Rules returns a Prometheus rule file with, for each SLO, recording rules of its error
+// ratio over every alert window and the multi-window multi-burn-rate alerts on them.
+// Reference it from prometheus.yml under rule_files.
+func Rules(slos ...SLO) ([]byte, error) {
+	var file ruleFile
+	for _, s := range slos {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		file.Groups = append(file.Groups, ruleGroup{Name: "slo-" + s.Name, Rules: sloRules(s)})
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+func sloRules(s SLO) []rule {
+	labels := map[string]string{sloLabel: s.Name}
+	selector := fmt.Sprintf(`{%s=%q}`, sloLabel, s.Name)
+
+	var rules []rule
+	recorded := make(map[time.Duration]bool)
+	for _, alert := range burnRateAlerts {
+		for _, window := range []time.Duration{alert.long, alert.short} {
+			if recorded[window] {
+				continue
+			}
+			recorded[window] = true
+
+			rangeSelector := "[" + promDuration(window) + "]"
+			rules = append(rules, rule{
+				Record: errorRatioRecord + promDuration(window),
+				Expr: fmt.Sprintf("1 - (sum(rate(%s%s%s)) / sum(rate(%s%s%s)))",
+					GoodEventsTotal, selector, rangeSelector, EventsTotal, selector, rangeSelector),
+				Labels: labels,
+			})
+		}
+	}
+
+	for _, alert := range burnRateAlerts {
+		burnRate := math.Round(alert.budgetSpent*float64(s.window())/float64(alert.long)*1000) / 1000
+		threshold := strconv.FormatFloat(burnRate, 'g', -1, 64) + " * " + strconv.FormatFloat(s.ErrorBudget(), 'g', 6, 64)
+		rules = append(rules, rule{
+			Alert: "SLOErrorBudgetBurn",
+			Expr: fmt.Sprintf("%s%s%s > (%s)\nand\n%s%s%s > (%s)",
+				errorRatioRecord, promDuration(alert.long), selector, threshold,
+				errorRatioRecord, promDuration(alert.short), selector, threshold),
+			For: promDuration(alert.forDuration),
+			Labels: map[string]string{
+				sloLabel:   s.Name,
+				"severity": alert.severity,
+			},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("SLO %s is burning its error budget %.3gx too fast", s.Name, burnRate),
+				"description": fmt.Sprintf("%s%% of the %s error budget of %s will be spent within %s at the current rate.",
+					strconv.FormatFloat(alert.budgetSpent*100, 'g', -1, 64), promDuration(s.window()), strings.TrimSpace(s.Method+" "+s.Route), promDuration(alert.long)),
+			},
+		})
+	}
+	return rules
+}
+
+// promDuration formats d in the Prometheus duration syntax, e.g. 5m, 1h or 3d.
+func promDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
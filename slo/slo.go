@@ -0,0 +1,117 @@
+package slo
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultWindow = 30 * 24 * time.Hour
+
+// SLO is a service level objective on the requests of a route, e.g. 99.5% of GET /user
+// requests succeed in under 300ms over 30 days. A request is a good event if it is not
+// answered with a 5xx status and, when Latency is set, completes within Latency.
+type SLO struct {
+	Name   string `yaml:"name"`
+	Route  string `yaml:"route"`
+	Method string `yaml:"method,omitempty"`
+	// Objective is the target ratio of good events, e.g. 0.995.
+	Objective float64  `yaml:"objective"`
+	Latency   Duration `yaml:"latency,omitempty"`
+	// Window is the compliance period. Defaults to 30 days.
+	Window Duration `yaml:"window,omitempty"`
+}
+
+// Duration is a time.Duration read from config as "300ms", "1h" or a number of days such as "30d".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Validate reports whether the SLO is complete and its objective is a ratio below 1.
+func (s SLO) Validate() error {
+	switch {
+	case s.Name == "":
+		return fmt.Errorf("slo: name is required")
+	case s.Route == "":
+		return fmt.Errorf("slo %q: route is required", s.Name)
+	case s.Objective <= 0 || s.Objective >= 1:
+		return fmt.Errorf("slo %q: objective %v must be between 0 and 1", s.Name, s.Objective)
+	case s.Latency < 0 || s.Window < 0:
+		return fmt.Errorf("slo %q: latency and window must not be negative", s.Name)
+	}
+	return nil
+}
+
+func (s SLO) window() time.Duration {
+	if s.Window == 0 {
+		return defaultWindow
+	}
+	return time.Duration(s.Window)
+}
+
+// ErrorBudget is the ratio of bad events the SLO allows.
+func (s SLO) ErrorBudget() float64 {
+	return 1 - s.Objective
+}
+
+// Good reports whether a request answered with status after duration meets the SLO.
+func (s SLO) Good(status int, duration time.Duration) bool {
+	if status >= 500 {
+		return false
+	}
+	return s.Latency == 0 || duration <= time.Duration(s.Latency)
+}
+
+func (s SLO) matches(method, route string) bool {
+	return route == s.Route && (s.Method == "" || strings.EqualFold(method, s.Method))
+}
+
+// Load reads SLOs from YAML of the form:
+//
+//	slos:
+//	  - name: user-latency
+//	    route: /user
+//	    method: GET
+//	    objective: 0.995
+//	    latency: 300ms
+//	    window: 30d
+func Load(r io.Reader) ([]SLO, error) {
+	var config struct {
+		SLOs []SLO `yaml:"slos"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, fmt.Errorf("slo: decoding config: %w", err)
+	}
+	for _, s := range config.SLOs {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config.SLOs, nil
+}
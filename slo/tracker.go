@@ -0,0 +1,125 @@
+package slo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	GoodEventsTotal      = "slo_good_events_total"
+	EventsTotal          = "slo_events_total"
+	SLIRatio             = "slo_sli_ratio"
+	ErrorBudgetRemaining = "slo_error_budget_remaining_ratio"
+	ObjectiveRatio       = "slo_objective_ratio"
+	sloLabel             = "slo"
+)
+
+// Tracker counts the good and total events of a set of SLOs from the requests of a gin
+// router. The SLI and error budget gauges cover the events since the process started;
+// the recording rules from Rules compute them over sliding windows.
+type Tracker struct {
+	slos      []SLO
+	good      *prometheus.CounterVec
+	total     *prometheus.CounterVec
+	sli       *prometheus.GaugeVec
+	budget    *prometheus.GaugeVec
+	objective *prometheus.GaugeVec
+
+	mu     sync.Mutex
+	counts []eventCount
+}
+
+type eventCount struct {
+	good, total float64
+}
+
+// NewTracker validates slos and registers their metrics on reg, or the default registry if reg is nil.
+func NewTracker(reg prometheus.Registerer, slos ...SLO) (*Tracker, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	seen := make(map[string]struct{}, len(slos))
+	for _, s := range slos {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[s.Name]; ok {
+			return nil, fmt.Errorf("slo %q: declared more than once", s.Name)
+		}
+		seen[s.Name] = struct{}{}
+	}
+
+	labels := []string{sloLabel}
+	t := &Tracker{
+		slos:      slos,
+		good:      prometheus.NewCounterVec(prometheus.CounterOpts{Name: GoodEventsTotal, Help: "Total number of events meeting the SLO"}, labels),
+		total:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: EventsTotal, Help: "Total number of events counted by the SLO"}, labels),
+		sli:       prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: SLIRatio, Help: "Ratio of good events since the process started"}, labels),
+		budget:    prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: ErrorBudgetRemaining, Help: "Ratio of the error budget left since the process started"}, labels),
+		objective: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: ObjectiveRatio, Help: "Target ratio of good events of the SLO"}, labels),
+		counts:    make([]eventCount, len(slos)),
+	}
+
+	for _, collector := range []prometheus.Collector{t.good, t.total, t.sli, t.budget, t.objective} {
+		if err := reg.Register(collector); err != nil {
+			return nil, fmt.Errorf("slo: registering metrics: %w", err)
+		}
+	}
+	for _, s := range slos {
+		t.objective.WithLabelValues(s.Name).Set(s.Objective)
+		t.budget.WithLabelValues(s.Name).Set(1)
+		// Initialize the counters so rate() sees the series before the first request.
+		t.good.WithLabelValues(s.Name)
+		t.total.WithLabelValues(s.Name)
+	}
+	return t, nil
+}
+
+// Middleware records each request against the SLOs declared on its route.
+func (t *Tracker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		t.Record(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
+
+// Record counts a request to route answered with status after duration.
+func (t *Tracker) Record(method, route string, status int, duration time.Duration) {
+	for i, s := range t.slos {
+		if !s.matches(method, route) {
+			continue
+		}
+		good := s.Good(status, duration)
+
+		t.total.WithLabelValues(s.Name).Inc()
+		if good {
+			t.good.WithLabelValues(s.Name).Inc()
+		}
+		t.update(i, good)
+	}
+}
+
+// update refreshes the SLI and error budget gauges. The budget goes negative once overspent.
+func (t *Tracker) update(i int, good bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Gauges are derived from counts kept here, since reading a counter back is not supported.
+	count := &t.counts[i]
+	count.total++
+	if good {
+		count.good++
+	}
+
+	s := t.slos[i]
+	sli := count.good / count.total
+	remaining := 1 - (1-sli)/s.ErrorBudget()
+	t.sli.WithLabelValues(s.Name).Set(sli)
+	t.budget.WithLabelValues(s.Name).Set(remaining)
+}
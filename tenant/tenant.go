@@ -0,0 +1,44 @@
+package apw_tenant
+
+import (
+	"context"
+
+	"github.com/kyon1313/observability/internal/w3cbaggage"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const (
+	// BaggageKey is the baggage member, span attribute and log field carrying the tenant.
+	BaggageKey = "tenant_id"
+	// UnknownLabel is the metrics label value for requests without a tenant.
+	UnknownLabel = "unknown"
+)
+
+type contextKey struct{}
+
+// NewContext stores the tenant in ctx and merges it into the baggage so it propagates
+// to downstream services. The context value is kept even if the baggage member is invalid
+// or would exceed the W3C baggage limits.
+func NewContext(ctx context.Context, tenantID string) (context.Context, error) {
+	ctx = context.WithValue(ctx, contextKey{}, tenantID)
+
+	member, err := baggage.NewMemberRaw(BaggageKey, tenantID)
+	if err != nil {
+		return ctx, err
+	}
+	bag, err := w3cbaggage.SetMember(baggage.FromContext(ctx), member)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// FromContext returns the tenant stored by NewContext or, for requests from upstream
+// services, the tenant propagated in the baggage. It returns "" if there is none.
+func FromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(contextKey{}).(string); ok {
+		return tenantID
+	}
+	return baggage.FromContext(ctx).Member(BaggageKey).Value()
+}
@@ -0,0 +1,43 @@
+package apw_tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestNewContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		tenantID    string
+		wantErr     bool
+		wantBaggage string
+	}{
+		{"valid", "acme", false, "acme"},
+		{"over the W3C member limit", strings.Repeat("t", 5000), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := NewContext(context.Background(), tt.tenantID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewContext error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := FromContext(ctx); got != tt.tenantID {
+				t.Errorf("FromContext() = %q, want %q", got, tt.tenantID)
+			}
+			if got := baggage.FromContext(ctx).Member(BaggageKey).Value(); got != tt.wantBaggage {
+				t.Errorf("baggage %s = %q, want %q", BaggageKey, got, tt.wantBaggage)
+			}
+		})
+	}
+}
+
+func TestFromContextReadsPropagatedBaggage(t *testing.T) {
+	member, _ := baggage.NewMemberRaw(BaggageKey, "acme")
+	bag, _ := baggage.New(member)
+	if got := FromContext(baggage.ContextWithBaggage(context.Background(), bag)); got != "acme" {
+		t.Errorf("FromContext() = %q, want acme", got)
+	}
+}
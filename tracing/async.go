@@ -0,0 +1,53 @@
+package _tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartLinkedSpan starts a new root span that links to the given spans instead of
+// being their child. Use it for work that outlives or is decoupled from the caller.
+func (t *tracing) StartLinkedSpan(ctx context.Context, spanName string, links ...trace.Link) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, spanName, trace.WithNewRoot(), trace.WithLinks(links...))
+}
+
+// Detach returns a context that keeps every value of ctx, such as baggage and loggers,
+// but is never cancelled and carries no parent span.
+func (t *tracing) Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(detachedContext{parent: ctx}, trace.SpanContext{})
+}
+
+// Go runs fn in a new goroutine inside a span linked to the span in ctx. The goroutine
+// runs on a detached context, so it survives the end of the request that spawned it.
+// Panics are recovered and recorded on the span together with the stack trace.
+func (t *tracing) Go(ctx context.Context, spanName string, fn func(ctx context.Context) error) {
+	link := trace.LinkFromContext(ctx, attribute.String("link.type", "spawned_by"))
+	ctx, span := t.StartLinkedSpan(t.Detach(ctx), spanName, link)
+
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				t.RecordPanic(span, spanName, r)
+				span.End()
+				return
+			}
+			t.EndSpan(span, err)
+		}()
+
+		err = fn(ctx)
+	}()
+}
+
+// detachedContext exposes the values of its parent without its deadline or cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any         { return c.parent.Value(key) }
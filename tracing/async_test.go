@@ -0,0 +1,109 @@
+package _tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apw_logging "github.com/kyon1313/observability/logs"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecordedTracing(t *testing.T) (OtelTracing, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return NewTracing(provider.Tracer("async_test"), apw_logging.NewOtelLogging()), recorder
+}
+
+// endedSpan waits for the span named name to end.
+func endedSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, span := range recorder.Ended() {
+			if span.Name() == name {
+				return span
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("span %q did not end", name)
+	return nil
+}
+
+type requestIDKey struct{}
+
+func TestDetach(t *testing.T) {
+	tracing, _ := newRecordedTracing(t)
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), requestIDKey{}, "r-1"), time.Hour)
+	ctx = tracing.AddBaggage(ctx, "tenant_id", "acme")
+	ctx, span := tracing.StartSpan(ctx, "request")
+	defer span.End()
+	cancel()
+
+	detached := tracing.Detach(ctx)
+	if detached.Err() != nil || detached.Done() != nil {
+		t.Errorf("detached context is cancelled: %v", detached.Err())
+	}
+	if _, ok := detached.Deadline(); ok {
+		t.Error("detached context has a deadline")
+	}
+	if got := detached.Value(requestIDKey{}); got != "r-1" {
+		t.Errorf("value = %v, want r-1", got)
+	}
+	if got := tracing.GetBaggage(detached, "tenant_id"); got != "acme" {
+		t.Errorf("baggage tenant_id = %q, want acme", got)
+	}
+	if trace.SpanContextFromContext(detached).IsValid() {
+		t.Error("detached context carries the parent span")
+	}
+}
+
+func TestGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(ctx context.Context) error
+		wantCode codes.Code
+	}{
+		{"success", func(context.Context) error { return nil }, codes.Ok},
+		{"error", func(context.Context) error { return errors.New("boom") }, codes.Error},
+		{"panic", func(context.Context) error { panic("boom") }, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracing, recorder := newRecordedTracing(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			ctx, parent := tracing.StartSpan(ctx, "request")
+
+			jobCtx := make(chan context.Context, 1)
+			tracing.Go(ctx, "job", func(ctx context.Context) error {
+				jobCtx <- ctx
+				return tt.fn(ctx)
+			})
+			parent.End()
+			cancel()
+
+			span := endedSpan(t, recorder, "job")
+			if span.Parent().IsValid() {
+				t.Errorf("job span has parent %v, want a new root", span.Parent().SpanID())
+			}
+			links := span.Links()
+			if len(links) != 1 || links[0].SpanContext.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("links = %v, want a link to the request span", links)
+			}
+			if got := span.Status().Code; got != tt.wantCode {
+				t.Errorf("status = %v, want %v", got, tt.wantCode)
+			}
+			if err := (<-jobCtx).Err(); err != nil {
+				t.Errorf("job context error = %v, want none after the request is cancelled", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package _tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxAttributeDepth bounds how deep nested structs and maps are flattened.
+const maxAttributeDepth = 5
+
+// durationSuffix is appended to the key of time.Duration values, recorded in seconds.
+const durationSuffix = "_seconds"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// AddAttributesFromStruct adds every exported field of the struct v as a span attribute.
+// The name comes from the `otel:"name"` tag, falling back to the json tag and then the
+// field name. Fields tagged `otel:"-"` or `filter:"true"` are skipped, and nested
+// structs are flattened as "<parent>.<field>".
+func (t *tracing) AddAttributesFromStruct(span trace.Span, v any) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	span.SetAttributes(structAttributes("", rv, 0)...)
+}
+
+// ToAttributes converts value into one or more attributes under key. Scalars, their
+// slices, time.Time (RFC3339), errors, fmt.Stringer and json.Marshaler map to a single
+// attribute, and time.Duration to a float of seconds under "<key>_seconds"; structs and
+// maps are flattened into "<key>.<field>".
+func ToAttributes(key string, value any) []attribute.KeyValue {
+	return valueAttributes(key, reflect.ValueOf(value), 0)
+}
+
+func valueAttributes(key string, v reflect.Value, depth int) []attribute.KeyValue {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+
+	// Interfaces are checked before dereferencing so that pointer receivers still match.
+	if kv, ok := interfaceAttribute(key, v); ok {
+		return []attribute.KeyValue{kv}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return valueAttributes(key, v.Elem(), depth)
+	case reflect.String:
+		return []attribute.KeyValue{attribute.String(key, v.String())}
+	case reflect.Bool:
+		return []attribute.KeyValue{attribute.Bool(key, v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []attribute.KeyValue{attribute.Int64(key, v.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return []attribute.KeyValue{attribute.Int64(key, int64(u))}
+		}
+		return []attribute.KeyValue{attribute.String(key, fmt.Sprintf("%d", v.Uint()))}
+	case reflect.Float32, reflect.Float64:
+		return []attribute.KeyValue{attribute.Float64(key, v.Float())}
+	case reflect.Slice, reflect.Array:
+		return []attribute.KeyValue{sliceAttribute(key, v)}
+	case reflect.Struct:
+		if depth >= maxAttributeDepth {
+			return []attribute.KeyValue{attribute.String(key, fmt.Sprintf("%v", v.Interface()))}
+		}
+		return structAttributes(key, v, depth+1)
+	case reflect.Map:
+		if depth >= maxAttributeDepth {
+			return []attribute.KeyValue{attribute.String(key, fmt.Sprintf("%v", v.Interface()))}
+		}
+		var attrs []attribute.KeyValue
+		iter := v.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, valueAttributes(joinKey(key, fmt.Sprint(iter.Key().Interface())), iter.Value(), depth+1)...)
+		}
+		return attrs
+	default:
+		if v.CanInterface() {
+			return []attribute.KeyValue{attribute.String(key, fmt.Sprintf("%v", v.Interface()))}
+		}
+		return nil
+	}
+}
+
+// interfaceAttribute handles the types that have a dedicated representation regardless of kind.
+func interfaceAttribute(key string, v reflect.Value) (attribute.KeyValue, bool) {
+	if !v.CanInterface() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return attribute.KeyValue{}, false
+	}
+
+	switch {
+	case v.Type() == timeType:
+		return attribute.String(key, v.Interface().(time.Time).Format(time.RFC3339)), true
+	case v.Type() == durationType:
+		if !strings.HasSuffix(key, durationSuffix) {
+			key += durationSuffix
+		}
+		return attribute.Float64(key, v.Interface().(time.Duration).Seconds()), true
+	case v.Type() == reflect.TypeOf(attribute.Value{}):
+		return attribute.KeyValue{Key: attribute.Key(key), Value: v.Interface().(attribute.Value)}, true
+	case v.Type().Implements(errorType):
+		return attribute.String(key, v.Interface().(error).Error()), true
+	case v.Type().Implements(stringerType):
+		return attribute.String(key, v.Interface().(fmt.Stringer).String()), true
+	case v.Type().Implements(marshalerType):
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return attribute.String(key, fmt.Sprintf("%v", v.Interface())), true
+		}
+		return attribute.String(key, string(b)), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+func sliceAttribute(key string, v reflect.Value) attribute.KeyValue {
+	switch s := v.Interface().(type) {
+	case []string:
+		return attribute.StringSlice(key, s)
+	case []bool:
+		return attribute.BoolSlice(key, s)
+	case []int:
+		return attribute.IntSlice(key, s)
+	case []int64:
+		return attribute.Int64Slice(key, s)
+	case []float64:
+		return attribute.Float64Slice(key, s)
+	case []byte:
+		return attribute.String(key, string(s))
+	}
+
+	n := v.Len()
+	if v.Type().Elem() == durationType {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = time.Duration(v.Index(i).Int()).Seconds()
+		}
+		if !strings.HasSuffix(key, durationSuffix) {
+			key += durationSuffix
+		}
+		return attribute.Float64Slice(key, out)
+	}
+	// Like single values, elements with a dedicated representation are recorded as strings.
+	if elem := v.Type().Elem(); elem == timeType || elem.Implements(errorType) ||
+		elem.Implements(stringerType) || elem.Implements(marshalerType) {
+		return stringSliceAttribute(key, v)
+	}
+	switch v.Type().Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out := make([]int64, n)
+		for i := range out {
+			out[i] = v.Index(i).Int()
+		}
+		return attribute.Int64Slice(key, out)
+	case reflect.Float32, reflect.Float64:
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = v.Index(i).Float()
+		}
+		return attribute.Float64Slice(key, out)
+	case reflect.Bool:
+		out := make([]bool, n)
+		for i := range out {
+			out[i] = v.Index(i).Bool()
+		}
+		return attribute.BoolSlice(key, out)
+	default:
+		return stringSliceAttribute(key, v)
+	}
+}
+
+func stringSliceAttribute(key string, v reflect.Value) attribute.KeyValue {
+	out := make([]string, v.Len())
+	for i := range out {
+		if kv, ok := interfaceAttribute(key, v.Index(i)); ok {
+			out[i] = kv.Value.Emit()
+		} else {
+			out[i] = fmt.Sprintf("%v", v.Index(i).Interface())
+		}
+	}
+	return attribute.StringSlice(key, out)
+}
+
+func structAttributes(prefix string, v reflect.Value, depth int) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("filter") == "true" {
+			continue
+		}
+
+		name := attributeName(field)
+		if name == "-" {
+			continue
+		}
+		attrs = append(attrs, valueAttributes(joinKey(prefix, name), v.Field(i), depth)...)
+	}
+	return attrs
+}
+
+func attributeName(field reflect.StructField) string {
+	for _, tagKey := range []string{"otel", "json"} {
+		if tag, ok := field.Tag.Lookup(tagKey); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
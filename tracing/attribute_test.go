@@ -0,0 +1,133 @@
+package _tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type level int
+
+func (l level) String() string { return [...]string{"low", "high"}[l] }
+
+type address struct {
+	City string `json:"city"`
+	Zip  string `otel:"postal_code" json:"zip"`
+}
+
+type customer struct {
+	ID       int64         `otel:"customer.id"`
+	Name     string        `json:"name"`
+	Password string        `filter:"true"`
+	Internal string        `otel:"-"`
+	Address  *address      `json:"address"`
+	Timeout  time.Duration `json:"timeout"`
+	Tags     []string      `json:"tags"`
+	secret   string
+}
+
+// attributeMap returns attrs by key, with each value in its emitted form.
+func attributeMap(attrs []attribute.KeyValue) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		out[string(kv.Key)] = kv.Value.Type().String() + ":" + kv.Value.Emit()
+	}
+	return out
+}
+
+func TestToAttributes(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value any
+		want  map[string]string
+	}{
+		{"string", "acme", map[string]string{"v": "STRING:acme"}},
+		{"bool", true, map[string]string{"v": "BOOL:true"}},
+		{"int kinds", int8(-3), map[string]string{"v": "INT64:-3"}},
+		{"small uint", uint32(7), map[string]string{"v": "INT64:7"}},
+		{"uint over int64", uint64(1 << 63), map[string]string{"v": "STRING:9223372036854775808"}},
+		{"float", float32(0.5), map[string]string{"v": "FLOAT64:0.5"}},
+		{"nil pointer", (*address)(nil), map[string]string{}},
+		{"time", created, map[string]string{"v": "STRING:2024-05-01T12:00:00Z"}},
+		{"duration", 1500 * time.Millisecond, map[string]string{"v_seconds": "FLOAT64:1.5"}},
+		{"error", errors.New("boom"), map[string]string{"v": "STRING:boom"}},
+		{"stringer", level(1), map[string]string{"v": "STRING:high"}},
+		{"bytes", []byte("raw"), map[string]string{"v": "STRING:raw"}},
+		{"int slice", []int32{1, 2}, map[string]string{"v": "INT64SLICE:[1,2]"}},
+		{"duration slice", []time.Duration{time.Second}, map[string]string{"v_seconds": "FLOAT64SLICE:[1]"}},
+		{"stringer slice", []level{0, 1}, map[string]string{"v": `STRINGSLICE:["low","high"]`}},
+		{"map", map[string]int{"a": 1, "b": 2}, map[string]string{"v.a": "INT64:1", "v.b": "INT64:2"}},
+		{"struct", address{City: "Oslo", Zip: "0150"}, map[string]string{"v.city": "STRING:Oslo", "v.postal_code": "STRING:0150"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := attributeMap(ToAttributes("v", tt.value))
+			if len(got) != len(tt.want) {
+				t.Fatalf("ToAttributes() = %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %s, want %s", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestToAttributesBoundsDepth(t *testing.T) {
+	type node struct {
+		Next map[string]any `json:"next"`
+	}
+	value := map[string]any{}
+	for i := 0; i < 2*maxAttributeDepth; i++ {
+		value = map[string]any{"n": node{Next: value}}
+	}
+	attrs := ToAttributes("v", value)
+	if len(attrs) != 1 || attrs[0].Value.Type() != attribute.STRING {
+		t.Fatalf("ToAttributes() = %v, want a single attribute formatted as a string", attrs)
+	}
+}
+
+func TestAddAttributesFromStruct(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+	tracing := &tracing{tracer: provider.Tracer("attribute_test")}
+
+	_, span := provider.Tracer("attribute_test").Start(context.Background(), "op")
+	tracing.AddAttributesFromStruct(span, &customer{
+		ID:       42,
+		Name:     "bob",
+		Password: "hunter2",
+		Internal: "x",
+		Address:  &address{City: "Oslo"},
+		Timeout:  2 * time.Second,
+		Tags:     []string{"vip"},
+		secret:   "s",
+	})
+	span.End()
+
+	want := map[string]string{
+		"customer.id":         "INT64:42",
+		"name":                "STRING:bob",
+		"address.city":        "STRING:Oslo",
+		"address.postal_code": "STRING:",
+		"timeout_seconds":     "FLOAT64:2",
+		"tags":                `STRINGSLICE:["vip"]`,
+	}
+	got := attributeMap(recorder.Ended()[0].Attributes())
+	if len(got) != len(want) {
+		t.Fatalf("attributes = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %s, want %s", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package _tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kyon1313/observability/internal/w3cbaggage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Errors returned when baggage exceeds the W3C limits.
+var (
+	ErrBaggageTooManyMembers = w3cbaggage.ErrTooManyMembers
+	ErrBaggageMemberTooLarge = w3cbaggage.ErrMemberTooLarge
+	ErrBaggageTooLarge       = w3cbaggage.ErrTooLarge
+)
+
+// AddBaggage merges a key-value pair into the baggage in the context. Invalid members are
+// logged and the context is returned unchanged; use SetBaggageMember to handle the error.
+func (t *tracing) AddBaggage(ctx context.Context, key, value string) context.Context {
+	newCtx, err := t.SetBaggageMember(ctx, key, value)
+	if err != nil {
+		t.l.Warnf("failed to add baggage %q: %v", key, err)
+		return ctx
+	}
+	return newCtx
+}
+
+// SetBaggage merges all members into the baggage in the context. Nothing is applied if any
+// member is invalid or the result exceeds the W3C limits.
+func (t *tracing) SetBaggage(ctx context.Context, members map[string]string) (context.Context, error) {
+	bag := baggage.FromContext(ctx)
+	for key, value := range members {
+		member, err := baggage.NewMemberRaw(key, value)
+		if err != nil {
+			return ctx, fmt.Errorf("baggage member %q: %w", key, err)
+		}
+		if bag, err = w3cbaggage.SetMember(bag, member); err != nil {
+			return ctx, err
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// SetBaggageMember merges a single member with optional properties into the baggage in the context.
+func (t *tracing) SetBaggageMember(ctx context.Context, key, value string, props ...baggage.Property) (context.Context, error) {
+	member, err := baggage.NewMemberRaw(key, value, props...)
+	if err != nil {
+		return ctx, fmt.Errorf("baggage member %q: %w", key, err)
+	}
+	bag, err := w3cbaggage.SetMember(baggage.FromContext(ctx), member)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// RemoveBaggage removes key from the baggage in the context.
+func (t *tracing) RemoveBaggage(ctx context.Context, key string) context.Context {
+	return baggage.ContextWithBaggage(ctx, baggage.FromContext(ctx).DeleteMember(key))
+}
+
+// GetBaggage retrieves the value of a key from the baggage in the context.
+func (t *tracing) GetBaggage(ctx context.Context, key string) string {
+	bag := baggage.FromContext(ctx)
+	member := bag.Member(key)
+	return member.Value()
+}
+
+// baggageSpanProcessor copies allowlisted baggage members onto every span as attributes.
+type baggageSpanProcessor struct {
+	keys map[string]struct{}
+}
+
+// NewBaggageSpanProcessor returns a span processor that sets each baggage member whose
+// key is in keys as a span attribute of the same name when the span starts.
+func NewBaggageSpanProcessor(keys ...string) sdktrace.SpanProcessor {
+	allowed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		allowed[key] = struct{}{}
+	}
+	return &baggageSpanProcessor{keys: allowed}
+}
+
+func (p *baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, member := range baggage.FromContext(parent).Members() {
+		if _, ok := p.keys[member.Key()]; ok {
+			s.SetAttributes(attribute.String(member.Key(), member.Value()))
+		}
+	}
+}
+
+func (p *baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (p *baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (p *baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
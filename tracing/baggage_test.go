@@ -0,0 +1,119 @@
+package _tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kyon1313/observability/internal/w3cbaggage"
+	apw_logging "github.com/kyon1313/observability/logs"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func newTestTracing() OtelTracing {
+	return NewTracing(noop.NewTracerProvider().Tracer("baggage_test"), apw_logging.NewOtelLogging())
+}
+
+// baggageContext returns a context whose baggage holds n members k0, k1, ... with value.
+func baggageContext(t *testing.T, n int, value string) context.Context {
+	t.Helper()
+	members := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		members[fmt.Sprintf("k%d", i)] = value
+	}
+	ctx, err := newTestTracing().SetBaggage(context.Background(), members)
+	if err != nil {
+		t.Fatalf("SetBaggage: %v", err)
+	}
+	return ctx
+}
+
+func TestSetBaggageMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		key     string
+		value   string
+		wantErr error
+	}{
+		{"valid", context.Background(), "tenant_id", "acme", nil},
+		{"replaces a member at the member limit", baggageContext(t, w3cbaggage.MaxMembers, "v"), "k0", "w", nil},
+		{"member too large", context.Background(), "blob", strings.Repeat("x", w3cbaggage.MaxMemberBytes), ErrBaggageMemberTooLarge},
+		{"too many members", baggageContext(t, w3cbaggage.MaxMembers, "v"), "extra", "v", ErrBaggageTooManyMembers},
+		{"too large in total", baggageContext(t, 2, strings.Repeat("x", 3000)), "k2", strings.Repeat("x", 3000), ErrBaggageTooLarge},
+	}
+	tracing := newTestTracing()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := tracing.SetBaggageMember(tt.ctx, tt.key, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetBaggageMember error = %v, want %v", err, tt.wantErr)
+			}
+			want := tt.value
+			if err != nil {
+				// The context is returned unchanged.
+				want = tracing.GetBaggage(tt.ctx, tt.key)
+			}
+			if got := tracing.GetBaggage(ctx, tt.key); got != want {
+				t.Errorf("GetBaggage(%q) = %q, want %q", tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestSetBaggageAppliesNothingOnError(t *testing.T) {
+	tracing := newTestTracing()
+	ctx, err := tracing.SetBaggage(context.Background(), map[string]string{
+		"tenant_id": "acme",
+		"":          "v",
+	})
+	if err == nil {
+		t.Fatal("SetBaggage accepted an invalid key")
+	}
+	if got := tracing.GetBaggage(ctx, "tenant_id"); got != "" {
+		t.Errorf("GetBaggage(tenant_id) = %q, want no member", got)
+	}
+}
+
+func TestAddBaggageKeepsContextOnError(t *testing.T) {
+	tracing := newTestTracing()
+	ctx := tracing.AddBaggage(context.Background(), "tenant_id", "acme")
+	ctx = tracing.AddBaggage(ctx, "blob", strings.Repeat("x", w3cbaggage.MaxMemberBytes))
+
+	if got := tracing.GetBaggage(ctx, "tenant_id"); got != "acme" {
+		t.Errorf("GetBaggage(tenant_id) = %q, want acme", got)
+	}
+	if got := tracing.GetBaggage(ctx, "blob"); got != "" {
+		t.Errorf("oversized member was added")
+	}
+}
+
+func TestBaggageSpanProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewBaggageSpanProcessor("tenant_id")),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	defer provider.Shutdown(context.Background())
+
+	ctx, err := newTestTracing().SetBaggage(context.Background(), map[string]string{
+		"tenant_id": "acme",
+		"session":   "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("SetBaggage: %v", err)
+	}
+	_, span := provider.Tracer("baggage_test").Start(ctx, "op")
+	span.End()
+
+	attrs := recorder.Ended()[0].Attributes()
+	if len(attrs) != 1 || attrs[0] != attribute.String("tenant_id", "acme") {
+		t.Errorf("span attributes = %v, want only tenant_id=acme", attrs)
+	}
+}
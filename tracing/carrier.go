@@ -0,0 +1,128 @@
+package _tracing
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// The carriers below adapt common message header layouts to propagation.TextMapCarrier
+// so trace context and baggage can travel with a message across a queue.
+var (
+	_ propagation.TextMapCarrier = MapCarrier(nil)
+	_ propagation.TextMapCarrier = (*PairsCarrier)(nil)
+	_ propagation.TextMapCarrier = (*ByteHeadersCarrier)(nil)
+)
+
+// MapCarrier adapts map[string]string headers.
+type MapCarrier map[string]string
+
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// HeaderPair is a single string header, as used by brokers that allow repeated keys.
+type HeaderPair struct {
+	Key   string
+	Value string
+}
+
+// PairsCarrier adapts a slice of HeaderPair. Set replaces an existing key or appends a new one,
+// allocating the slice if Headers is nil.
+type PairsCarrier struct {
+	Headers *[]HeaderPair
+}
+
+func (c *PairsCarrier) Get(key string) string {
+	if c.Headers == nil {
+		return ""
+	}
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func (c *PairsCarrier) Set(key, value string) {
+	if c.Headers == nil {
+		c.Headers = &[]HeaderPair{}
+	}
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = value
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, HeaderPair{Key: key, Value: value})
+}
+
+func (c *PairsCarrier) Keys() []string {
+	if c.Headers == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// ByteHeader is a single header with a binary value, as used by Kafka-style brokers.
+type ByteHeader struct {
+	Key   string
+	Value []byte
+}
+
+// ByteHeadersCarrier adapts a slice of ByteHeader. Set replaces an existing key or appends a new one,
+// allocating the slice if Headers is nil.
+type ByteHeadersCarrier struct {
+	Headers *[]ByteHeader
+}
+
+func (c *ByteHeadersCarrier) Get(key string) string {
+	if c.Headers == nil {
+		return ""
+	}
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *ByteHeadersCarrier) Set(key, value string) {
+	if c.Headers == nil {
+		c.Headers = &[]ByteHeader{}
+	}
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, ByteHeader{Key: key, Value: []byte(value)})
+}
+
+func (c *ByteHeadersCarrier) Keys() []string {
+	if c.Headers == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
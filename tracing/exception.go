@@ -0,0 +1,44 @@
+package _tracing
+
+import (
+	apw_errors "github.com/kyon1313/observability/errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const exceptionPrefix = "exception."
+
+// recordException records err on the span. Errors created with the apw_errors package
+// are unpacked into exception.* attributes: category, code, stack trace, the trace and
+// span they were created in, and their fields.
+func recordException(span trace.Span, err error) {
+	span.RecordError(err, trace.WithAttributes(ExceptionAttributes(err)...))
+}
+
+// ExceptionAttributes returns the exception.* attributes carried by an apw_errors.Error in err's chain.
+func ExceptionAttributes(err error) []attribute.KeyValue {
+	e, ok := apw_errors.As(err)
+	if !ok {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{attribute.String(exceptionPrefix+"category", string(e.Category))}
+	if e.Code != "" {
+		attrs = append(attrs, attribute.String(exceptionPrefix+"code", e.Code))
+	}
+	if stack := e.StackTrace(); stack != "" {
+		attrs = append(attrs, semconv.ExceptionStacktrace(stack))
+	}
+	if e.TraceID != "" {
+		attrs = append(attrs,
+			attribute.String(exceptionPrefix+"trace_id", e.TraceID),
+			attribute.String(exceptionPrefix+"span_id", e.SpanID),
+		)
+	}
+	for _, f := range e.Fields {
+		attrs = append(attrs, ToAttributes(exceptionPrefix+f.Key, f.Value)...)
+	}
+	return attrs
+}
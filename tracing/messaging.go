@@ -0,0 +1,60 @@
+package _tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartProducerSpan starts a producer span for publishing to destination and injects
+// the resulting span context into carrier, which should hold the outgoing message headers.
+func (t *tracing) StartProducerSpan(ctx context.Context, system, destination string, carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		semconv.MessagingSystem(system),
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName(destination),
+	}, attrs...)
+
+	ctx, span := t.tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return ctx, span
+}
+
+// StartConsumerSpan starts a consumer span for processing messages received from source.
+// With a single carrier the span continues the producer's trace and picks up its baggage.
+// With several carriers (a batch) the span stays in ctx's trace and links to every origin trace.
+func (t *tracing) StartConsumerSpan(ctx context.Context, system, source string, carriers []propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	propagator := otel.GetTextMapPropagator()
+	attrs = append([]attribute.KeyValue{
+		semconv.MessagingSystem(system),
+		semconv.MessagingOperationProcess,
+		semconv.MessagingSourceName(source),
+	}, attrs...)
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindConsumer)}
+
+	switch len(carriers) {
+	case 0:
+	case 1:
+		ctx = propagator.Extract(ctx, carriers[0])
+	default:
+		links := make([]trace.Link, 0, len(carriers))
+		for _, carrier := range carriers {
+			sc := trace.SpanContextFromContext(propagator.Extract(context.Background(), carrier))
+			if sc.IsValid() {
+				links = append(links, trace.Link{SpanContext: sc})
+			}
+		}
+		attrs = append(attrs, semconv.MessagingBatchMessageCount(len(carriers)))
+		opts = append(opts, trace.WithLinks(links...))
+	}
+
+	opts = append(opts, trace.WithAttributes(attrs...))
+	return t.tracer.Start(ctx, source+" process", opts...)
+}
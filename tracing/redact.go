@@ -0,0 +1,156 @@
+package _tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	redactedValue  = "[REDACTED]"
+	truncatedValue = "[TRUNCATED]"
+	// maxRedactDepth bounds how deep values are walked, so that cyclic values terminate.
+	maxRedactDepth = 32
+)
+
+// Redact returns a JSON-friendly copy of v in which every struct field tagged
+// `filter:"true"` is replaced by a placeholder. Nested structs, pointers, slices
+// and maps are walked up to a fixed depth, below which values are truncated; other
+// values are returned unchanged.
+func Redact(v any) any {
+	return redactValue(reflect.ValueOf(v), 0)
+}
+
+// redactedJSON marshals v after redaction, for recording as a span attribute.
+func redactedJSON(v any) string {
+	if v == nil {
+		return "null"
+	}
+	jsonBytes, err := json.Marshal(Redact(v))
+	if err != nil {
+		return fmt.Sprintf("Failed to marshal response: %v", err)
+	}
+	return string(jsonBytes)
+}
+
+func redactValue(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return truncatedValue
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), depth+1)
+	case reflect.Struct:
+		if _, ok := v.Interface().(json.Marshaler); ok {
+			return v.Interface()
+		}
+		return redactStruct(v, depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redactValue(v.Index(i), depth+1)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value(), depth+1)
+		}
+		return out
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+}
+
+func redactStruct(v reflect.Value, depth int) map[string]any {
+	out := make(map[string]any, v.NumField())
+	var embedded []map[string]any
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged := field.Name, false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name, tagged = tagName, true
+			}
+		}
+		filtered := field.Tag.Get("filter") == "true"
+
+		// Like encoding/json, the fields of an embedded struct without a json name are
+		// promoted into the outer object.
+		if fv, ok := embeddedStruct(v.Field(i), field); ok && !tagged {
+			if !fv.IsValid() {
+				continue
+			}
+			if fields, ok := redactValue(fv, depth+1).(map[string]any); ok {
+				if filtered {
+					for key := range fields {
+						fields[key] = redactedValue
+					}
+				}
+				embedded = append(embedded, fields)
+				continue
+			}
+		}
+
+		if filtered {
+			out[name] = redactedValue
+			continue
+		}
+		out[name] = redactValue(v.Field(i), depth+1)
+	}
+
+	// Fields of the outer struct take precedence over promoted ones.
+	for _, fields := range embedded {
+		for key, value := range fields {
+			if _, ok := out[key]; !ok {
+				out[key] = value
+			}
+		}
+	}
+	return out
+}
+
+// embeddedStruct reports whether field is an embedded struct, or pointer to one, whose
+// fields are promoted, and returns its value, which is invalid for a nil pointer.
+func embeddedStruct(v reflect.Value, field reflect.StructField) (reflect.Value, bool) {
+	if !field.Anonymous {
+		return reflect.Value{}, false
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		return v.Elem(), true
+	}
+	return v, v.Kind() == reflect.Struct
+}
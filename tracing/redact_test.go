@@ -0,0 +1,78 @@
+package _tracing
+
+import "testing"
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" filter:"true"`
+}
+
+type Audit struct {
+	CreatedBy string `json:"created_by"`
+	ID        int    `json:"id"`
+}
+
+type Secret struct {
+	Token string `json:"token"`
+}
+
+type Order struct {
+	Audit
+	*Secret `filter:"true"`
+	ID      string      `json:"id"`
+	Login   credentials `json:"login"`
+}
+
+type TaggedOrder struct {
+	Audit `json:"audit"`
+	ID    string `json:"id"`
+}
+
+func TestRedactedJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"nil", nil, "null"},
+		{"filtered field", credentials{User: "bob", Password: "hunter2"}, `{"password":"[REDACTED]","user":"bob"}`},
+		{"nested struct", Order{ID: "o-1", Login: credentials{User: "bob", Password: "hunter2"}},
+			`{"created_by":"","id":"o-1","login":{"password":"[REDACTED]","user":"bob"}}`},
+		{"embedded structs are flattened", &Order{Audit: Audit{CreatedBy: "alice", ID: 7}, Secret: &Secret{Token: "t0k3n"}, ID: "o-1"},
+			`{"created_by":"alice","id":"o-1","login":{"password":"[REDACTED]","user":""},"token":"[REDACTED]"}`},
+		{"tagged embedded struct is nested", TaggedOrder{Audit: Audit{CreatedBy: "alice", ID: 7}, ID: "o-1"},
+			`{"audit":{"created_by":"alice","id":7},"id":"o-1"}`},
+		{"slice and map", map[string][]credentials{"admins": {{User: "root", Password: "toor"}}},
+			`{"admins":[{"password":"[REDACTED]","user":"root"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactedJSON(tt.v); got != tt.want {
+				t.Errorf("redactedJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactTruncatesCycles(t *testing.T) {
+	type node struct {
+		Next *node `json:"next"`
+	}
+	n := &node{}
+	n.Next = n
+
+	v := Redact(n)
+	for depth := 0; ; depth++ {
+		m, ok := v.(map[string]any)
+		if !ok {
+			if v != truncatedValue {
+				t.Fatalf("value at depth %d = %v, want %q", depth, v, truncatedValue)
+			}
+			return
+		}
+		if depth > maxRedactDepth {
+			t.Fatalf("cycle not truncated after %d levels", depth)
+		}
+		v = m["next"]
+	}
+}
@@ -0,0 +1,125 @@
+package _tracing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	apw_errors "github.com/kyon1313/observability/errors"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatusMapper maps an error to a span status. It returns ok=false for errors it does not handle.
+type StatusMapper func(err error) (code codes.Code, description string, ok bool)
+
+var (
+	statusMappersMu sync.RWMutex
+	statusMappers   = []StatusMapper{categoryStatus}
+)
+
+// categoryStatus leaves the status Unset for apw_errors in the NotFound and Validation
+// categories, which are expected outcomes rather than failures of the operation. It is
+// registered first, so mappers registered later override it.
+func categoryStatus(err error) (codes.Code, string, bool) {
+	if errors.Is(err, apw_errors.ErrNotFound) || errors.Is(err, apw_errors.ErrValidation) {
+		return codes.Unset, "", true
+	}
+	return codes.Unset, "", false
+}
+
+// RegisterStatusMapper adds a mapper consulted by ErrorStatus. Mappers registered later take precedence.
+func RegisterStatusMapper(mapper StatusMapper) {
+	statusMappersMu.Lock()
+	defer statusMappersMu.Unlock()
+	statusMappers = append(statusMappers, mapper)
+}
+
+// MapErrorStatus registers a mapper that gives every error matching target (per errors.Is)
+// the given status code, for example codes.Unset for an expected "not found".
+func MapErrorStatus(target error, code codes.Code) {
+	RegisterStatusMapper(func(err error) (codes.Code, string, bool) {
+		if !errors.Is(err, target) {
+			return codes.Unset, "", false
+		}
+		if code == codes.Error {
+			return code, err.Error(), true
+		}
+		return code, "", true
+	})
+}
+
+// ErrorStatus returns the span status for err: Unset for nil, the result of the most
+// recently registered matching mapper, or Error with the error message.
+func ErrorStatus(err error) (codes.Code, string) {
+	if err == nil {
+		return codes.Unset, ""
+	}
+
+	// Mappers run without the lock held, so that they may register other mappers.
+	statusMappersMu.RLock()
+	mappers := statusMappers
+	statusMappersMu.RUnlock()
+	for i := len(mappers) - 1; i >= 0; i-- {
+		if code, description, ok := mappers[i](err); ok {
+			return code, description
+		}
+	}
+	return codes.Error, err.Error()
+}
+
+// HTTPStatus maps an HTTP status code to a span status following the semantic conventions:
+// 1xx-3xx leave the status Unset, 4xx are errors only for client spans, 5xx and invalid
+// codes are always errors.
+func HTTPStatus(statusCode int, kind trace.SpanKind) (codes.Code, string) {
+	switch {
+	case statusCode < 100 || statusCode >= 600:
+		return codes.Error, fmt.Sprintf("Invalid HTTP status code %d", statusCode)
+	case statusCode >= 500, statusCode >= 400 && kind != trace.SpanKindServer:
+		return codes.Error, http.StatusText(statusCode)
+	default:
+		return codes.Unset, ""
+	}
+}
+
+// SetSpanHTTPStatus records statusCode as http.status_code and sets the matching span status.
+func SetSpanHTTPStatus(span trace.Span, kind trace.SpanKind, statusCode int) {
+	span.SetAttributes(semconv.HTTPStatusCode(statusCode))
+	code, description := HTTPStatus(statusCode, kind)
+	setStatus(span, code, description)
+}
+
+// SetSpanErrorStatus sets the span status returned by ErrorStatus for err.
+func SetSpanErrorStatus(span trace.Span, err error) {
+	code, description := ErrorStatus(err)
+	setStatus(span, code, description)
+}
+
+// SetHTTPStatus records an HTTP status code on the span using the span's own kind.
+func (t *tracing) SetHTTPStatus(span trace.Span, statusCode int) {
+	SetSpanHTTPStatus(span, spanKind(span), statusCode)
+}
+
+// SetErrorStatus sets the span status for err using the registered status mappers.
+func (t *tracing) SetErrorStatus(span trace.Span, err error) {
+	SetSpanErrorStatus(span, err)
+}
+
+// setStatus leaves the status untouched when code is Unset, so an earlier Ok or Error is kept.
+func setStatus(span trace.Span, code codes.Code, description string) {
+	if code != codes.Unset {
+		span.SetStatus(code, description)
+	}
+}
+
+// spanKind returns the kind of SDK spans and SpanKindUnspecified otherwise.
+func spanKind(span trace.Span) trace.SpanKind {
+	if ro, ok := span.(sdktrace.ReadOnlySpan); ok {
+		return ro.SpanKind()
+	}
+	return trace.SpanKindUnspecified
+}
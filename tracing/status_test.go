@@ -0,0 +1,102 @@
+package _tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	apw_errors "github.com/kyon1313/observability/errors"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	errCanceledOrder = errors.New("order canceled")
+	errRetryLater    = errors.New("retry later")
+)
+
+func init() {
+	MapErrorStatus(errCanceledOrder, codes.Unset)
+	MapErrorStatus(errRetryLater, codes.Error)
+	RegisterStatusMapper(func(err error) (codes.Code, string, bool) {
+		if errors.Is(err, errRetryLater) {
+			return codes.Ok, "", true
+		}
+		return codes.Unset, "", false
+	})
+}
+
+func TestErrorStatus(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name            string
+		err             error
+		wantCode        codes.Code
+		wantDescription string
+	}{
+		{"nil", nil, codes.Unset, ""},
+		{"plain error", errors.New("boom"), codes.Error, "boom"},
+		{"not found", apw_errors.New(ctx, apw_errors.NotFound, "no such user"), codes.Unset, ""},
+		{"wrapped validation", fmt.Errorf("create: %w", apw_errors.ErrValidation), codes.Unset, ""},
+		{"upstream", apw_errors.New(ctx, apw_errors.Upstream, "billing unavailable"), codes.Error, "billing unavailable"},
+		{"mapped to unset", fmt.Errorf("checkout: %w", errCanceledOrder), codes.Unset, ""},
+		{"later mapper wins", errRetryLater, codes.Ok, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, description := ErrorStatus(tt.err)
+			if code != tt.wantCode || description != tt.wantDescription {
+				t.Errorf("ErrorStatus() = (%v, %q), want (%v, %q)", code, description, tt.wantCode, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestErrorStatusMapperMayRegisterMappers(t *testing.T) {
+	errLazy := errors.New("lazy")
+	RegisterStatusMapper(func(err error) (codes.Code, string, bool) {
+		if errors.Is(err, errLazy) {
+			MapErrorStatus(errLazy, codes.Unset)
+		}
+		return codes.Unset, "", false
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ErrorStatus(errLazy)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ErrorStatus deadlocked when a mapper registered another mapper")
+	}
+	if code, _ := ErrorStatus(errLazy); code != codes.Unset {
+		t.Errorf("status after registration = %v, want Unset", code)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		kind       trace.SpanKind
+		want       codes.Code
+	}{
+		{200, trace.SpanKindServer, codes.Unset},
+		{302, trace.SpanKindClient, codes.Unset},
+		{404, trace.SpanKindServer, codes.Unset},
+		{404, trace.SpanKindClient, codes.Error},
+		{404, trace.SpanKindUnspecified, codes.Error},
+		{503, trace.SpanKindServer, codes.Error},
+		{99, trace.SpanKindServer, codes.Error},
+		{600, trace.SpanKindClient, codes.Error},
+	}
+	for _, tt := range tests {
+		if got, _ := HTTPStatus(tt.statusCode, tt.kind); got != tt.want {
+			t.Errorf("HTTPStatus(%d, %v) = %v, want %v", tt.statusCode, tt.kind, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package _tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type traceConfig struct {
+	attrs  []attribute.KeyValue
+	kind   trace.SpanKind
+	result bool
+}
+
+// TraceOption configures a span started by Trace.
+type TraceOption func(*traceConfig)
+
+// WithAttributes sets attributes on the span when it starts.
+func WithAttributes(attrs ...attribute.KeyValue) TraceOption {
+	return func(c *traceConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// WithSpanKind sets the kind of the span, internal by default.
+func WithSpanKind(kind trace.SpanKind) TraceOption {
+	return func(c *traceConfig) {
+		c.kind = kind
+	}
+}
+
+// WithoutResult skips recording the returned value on the span.
+func WithoutResult() TraceOption {
+	return func(c *traceConfig) {
+		c.result = false
+	}
+}
+
+// Trace runs fn inside a span called spanName. The error and status are recorded,
+// the result is added as a redacted "response" event, and a panic in fn is recovered,
+// logged and returned as an error.
+func Trace[T any](ctx context.Context, t OtelTracing, spanName string, fn func(ctx context.Context) (T, error), opts ...TraceOption) (response T, err error) {
+	cfg := traceConfig{kind: trace.SpanKindInternal, result: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, span := t.StartSpan(ctx, spanName, trace.WithSpanKind(cfg.kind), trace.WithAttributes(cfg.attrs...))
+	defer func() {
+		if r := recover(); r != nil {
+			err = t.RecordPanic(span, spanName, r)
+			span.End()
+			return
+		}
+
+		if err != nil {
+			span.SetAttributes(errorSourceKey.String(spanName))
+		} else if cfg.result && span.IsRecording() {
+			t.AddEvent(span, spanName, attribute.String("response", redactedJSON(response)))
+		}
+		t.EndSpan(span, err)
+	}()
+
+	return fn(ctx)
+}
+
+// TraceErr is Trace for functions that only return an error.
+func TraceErr(ctx context.Context, t OtelTracing, spanName string, fn func(ctx context.Context) error, opts ...TraceOption) error {
+	_, err := Trace(ctx, t, spanName, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, append(opts, WithoutResult())...)
+	return err
+}
@@ -2,9 +2,9 @@ package _tracing
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 
 	_logging "github.com/kyon1313/observability/logs"
 	"go.opentelemetry.io/otel"
@@ -12,6 +12,7 @@ import (
 	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -20,24 +21,41 @@ type OtelTracing interface {
 	EndSpan(span trace.Span, err error)
 	SetStatus(span trace.Span, code codes.Code, description string)
 	AddAttribute(span trace.Span, key string, value any)
+	AddAttributesFromStruct(span trace.Span, v any)
 	AddEvent(span trace.Span, eventName string, attrs ...attribute.KeyValue)
 	SetOKStatus(span trace.Span, description string, attrs ...attribute.KeyValue)
 	SetNoContentStatus(span trace.Span, description string, attrs ...attribute.KeyValue)
+	SetHTTPStatus(span trace.Span, statusCode int)
+	SetErrorStatus(span trace.Span, err error)
 	GetTracer() trace.Tracer
 
 	AddAttributes(span trace.Span, err error, attrs ...attribute.KeyValue)
 	RecordError(span trace.Span, err error, source string)
+	RecordPanic(span trace.Span, spanName string, recovered any) error
 
 	ExtractSpanContext(ctx context.Context, r *http.Request) context.Context
 	InjectSpanContext(ctx context.Context, r *http.Request)
 	SpanFromContext(ctx context.Context) trace.Span
 	StartSpanFromContext(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span)
 
+	StartLinkedSpan(ctx context.Context, spanName string, links ...trace.Link) (context.Context, trace.Span)
+	Detach(ctx context.Context) context.Context
+	Go(ctx context.Context, spanName string, fn func(ctx context.Context) error)
+
+	StartProducerSpan(ctx context.Context, system, destination string, carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span)
+	StartConsumerSpan(ctx context.Context, system, source string, carriers []propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span)
+
 	AddBaggage(ctx context.Context, key, value string) context.Context
+	SetBaggage(ctx context.Context, members map[string]string) (context.Context, error)
+	SetBaggageMember(ctx context.Context, key, value string, props ...baggage.Property) (context.Context, error)
+	RemoveBaggage(ctx context.Context, key string) context.Context
 	GetBaggage(ctx context.Context, key string) string
 	LogTrace(span trace.Span, err *error, layer string, response any) func()
 }
 
+// errorSourceKey names the layer or span in which an error was recorded.
+const errorSourceKey = attribute.Key("error.source")
+
 type tracing struct {
 	tracer trace.Tracer
 	l      _logging.OtelLogging
@@ -50,24 +68,14 @@ func NewTracing(tracer trace.Tracer, l _logging.OtelLogging) OtelTracing {
 
 func (t *tracing) LogTrace(span trace.Span, err *error, layer string, response any) func() {
 	return func() {
-		if *err != nil && span.IsRecording() {
-			t.RecordError(span, *err, layer)
-			t.SetStatus(span, codes.Error, (*err).Error())
-		} else if *err == nil {
+		if *err != nil {
+			span.SetAttributes(errorSourceKey.String(layer))
+		} else {
 			t.SetOKStatus(span, "Operation completed successfully")
 		}
 
-		// Marshal the response to JSON
-		var jsonResponse string
-		if response != nil {
-			if jsonBytes, err := json.Marshal(response); err == nil {
-				jsonResponse = string(jsonBytes)
-			} else {
-				jsonResponse = fmt.Sprintf("Failed to marshal response: %v", err)
-			}
-		} else {
-			jsonResponse = "null"
-		}
+		// Marshal the response to JSON, hiding fields tagged filter:"true"
+		jsonResponse := redactedJSON(response)
 
 		// Record the response as an event
 		if span.IsRecording() {
@@ -86,8 +94,8 @@ func (t *tracing) StartSpan(ctx context.Context, spanName string, opts ...trace.
 // EndSpan ends the given span.
 func (t *tracing) EndSpan(span trace.Span, err error) {
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
+		recordException(span, err)
+		SetSpanErrorStatus(span, err)
 	} else {
 		span.SetStatus(codes.Ok, "Success")
 	}
@@ -99,20 +107,10 @@ func (t *tracing) SetStatus(span trace.Span, code codes.Code, description string
 	span.SetStatus(code, description)
 }
 
-// AddAttribute adds an attribute to the given span.
+// AddAttribute adds an attribute to the given span, converting value with ToAttributes.
+// Structs and maps are flattened into one attribute per field.
 func (t *tracing) AddAttribute(span trace.Span, key string, value any) {
-	switch v := value.(type) {
-	case string:
-		span.SetAttributes(attribute.String(key, v))
-	case int:
-		span.SetAttributes(attribute.Int(key, v))
-	case float64:
-		span.SetAttributes(attribute.Float64(key, v))
-	case bool:
-		span.SetAttributes(attribute.Bool(key, v))
-	default:
-		span.SetAttributes(attribute.String(key, fmt.Sprintf("%v", v)))
-	}
+	span.SetAttributes(ToAttributes(key, value)...)
 }
 
 // AddEvent records an event with a name and optional attributes in the given span.
@@ -126,28 +124,40 @@ func (t *tracing) SetOKStatus(span trace.Span, description string, attrs ...attr
 	span.SetAttributes(attrs...)
 }
 
-// SetNoContentStatus sets the status to indicate no content with an optional description and attributes.
+// SetNoContentStatus marks the operation as successful with an HTTP 204 status code,
+// an optional description and attributes.
 func (t *tracing) SetNoContentStatus(span trace.Span, description string, attrs ...attribute.KeyValue) {
-	span.SetStatus(codes.Code(http.StatusNoContent), description)
+	span.SetAttributes(semconv.HTTPStatusCode(http.StatusNoContent))
+	span.SetStatus(codes.Ok, description)
 	span.SetAttributes(attrs...)
 }
 
-// AddAttributes adds multiple attributes to the given span.
+// AddAttributes adds multiple attributes to the given span and, if err is set,
+// the span status mapped from it.
 func (t *tracing) AddAttributes(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
 	if err != nil {
-		span.SetAttributes(attrs...)
-		return
+		SetSpanErrorStatus(span, err)
 	}
-
-	attrs = append(attrs, attribute.Int("status.Code", 1))
-	span.SetAttributes(attrs...)
 }
 
-// RecordError records an error in the given span.
+// RecordError records an error in the given span. Do not call it before EndSpan with
+// the same error, which records the exception as well.
 func (t *tracing) RecordError(span trace.Span, err error, source string) {
-	span.RecordError(err)
+	recordException(span, err)
+	SetSpanErrorStatus(span, err)
+	span.SetAttributes(errorSourceKey.String(source))
+}
+
+// RecordPanic records a value recovered from a panic in spanName on the span together
+// with the stack trace, logs it and returns it as an error. The span is not ended.
+func (t *tracing) RecordPanic(span trace.Span, spanName string, recovered any) error {
+	err := fmt.Errorf("panic in %s: %v", spanName, recovered)
+	stack := string(debug.Stack())
+	span.RecordError(err, trace.WithAttributes(semconv.ExceptionStacktrace(stack)))
 	span.SetStatus(codes.Error, err.Error())
-	span.SetAttributes(attribute.String("error.source", source))
+	t.l.Errorw("recovered panic", "span", spanName, "panic", fmt.Sprint(recovered), "stacktrace", stack)
+	return err
 }
 
 // ExtractSpanContext extracts the span context from the incoming request headers.
@@ -176,17 +186,3 @@ func (t *tracing) StartSpanFromContext(ctx context.Context, spanName string, opt
 func (t *tracing) GetTracer() trace.Tracer {
 	return t.tracer
 }
-
-// AddBaggage adds a key-value pair to the baggage in the context.
-func (t *tracing) AddBaggage(ctx context.Context, key, value string) context.Context {
-	b, _ := baggage.NewMember(key, value)
-	bag, _ := baggage.New(b)
-	return baggage.ContextWithBaggage(ctx, bag)
-}
-
-// GetBaggage retrieves the value of a key from the baggage in the context.
-func (t *tracing) GetBaggage(ctx context.Context, key string) string {
-	bag := baggage.FromContext(ctx)
-	member := bag.Member(key)
-	return member.Value()
-}